@@ -0,0 +1,86 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+// Reload handles the Post /admin/reload REST call. The schemas, profiles and policy are loaded again and replace the active rules.
+// It returns a 200 code with the new rules, a 500 code is returned when loading fails, the active rules are kept.
+// Reloading must be enabled by the reload setting and only callers on the node itself may reload, otherwise a 403 code is returned.
+func (aw *ApiWrapper) Reload(ctx echo.Context) error {
+	if !aw.Vb.Config.Reload {
+		return ctx.String(http.StatusForbidden, "reloading the rules is disabled, enable it with the reload setting")
+	}
+	if !fromLoopback(ctx.Request()) {
+		return ctx.String(http.StatusForbidden, "reloading the rules is only allowed from the node itself")
+	}
+
+	info, err := aw.Vb.Reload()
+	if err != nil {
+		logrus.Error(err.Error())
+		return ctx.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return ctx.JSON(http.StatusOK, rulesInfoFrom(info))
+}
+
+// fromLoopback checks if the request comes from a loopback address. The remote address of the connection is used,
+// forwarded headers can be set by any caller. A reverse proxy on the node connects from a loopback address for every caller,
+// so the reload setting must stay off on such a node.
+func fromLoopback(req *http.Request) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// GetRules handles the Get /admin/rules REST call. It returns a 200 code with the version and hash of the active rules.
+func (aw *ApiWrapper) GetRules(ctx echo.Context) error {
+	info, err := aw.Vb.Rules()
+	if err != nil {
+		logrus.Error(err.Error())
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, rulesInfoFrom(info))
+}
+
+// rulesInfoFrom converts the rules info of the Validator to the API model
+func rulesInfoFrom(info pkg.RulesInfo) RulesInfo {
+	result := RulesInfo{
+		FhirVersions: []string{},
+		Hash:         info.Hash,
+		LoadedAt:     info.LoadedAt,
+		Profiles:     []string{},
+		Version:      info.Version,
+	}
+	result.FhirVersions = append(result.FhirVersions, info.FHIRVersions...)
+	result.Profiles = append(result.Profiles, info.Profiles...)
+	return result
+}
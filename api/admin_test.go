@@ -0,0 +1,115 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	"github.com/nuts-foundation/nuts-go-core/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiWrapper_Reload(t *testing.T) {
+	t.Run("reload returns the new rules", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+		client := validationBackend()
+		client.Vb.Config.Reload = true
+		active, _ := client.Vb.Rules()
+
+		echo.EXPECT().Request().Return(&http.Request{RemoteAddr: "127.0.0.1:50000"})
+		echo.EXPECT().JSON(http.StatusOK, gomock.Any()).DoAndReturn(func(code int, info RulesInfo) error {
+			assert.Equal(t, active.Version+1, info.Version)
+			assert.Equal(t, active.Hash, info.Hash)
+			assert.Equal(t, []string{pkg.NutsConsentProfile}, info.Profiles)
+			return nil
+		})
+
+		assert.NoError(t, client.Reload(echo))
+	})
+
+	t.Run("failed reload returns 500", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+		client := validationBackend()
+		client.Vb.Config.Reload = true
+		client.Vb.Config.Schemapath = "../schema/does_not_exist.json"
+
+		echo.EXPECT().Request().Return(&http.Request{RemoteAddr: "[::1]:50000"})
+		echo.EXPECT().String(http.StatusInternalServerError, gomock.Any())
+
+		assert.NoError(t, client.Reload(echo))
+	})
+
+	t.Run("reload from another host returns 403", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+		client := validationBackend()
+		client.Vb.Config.Reload = true
+		active, _ := client.Vb.Rules()
+
+		echo.EXPECT().Request().Return(&http.Request{RemoteAddr: "10.0.0.1:50000", Header: http.Header{"X-Forwarded-For": []string{"127.0.0.1"}}})
+		echo.EXPECT().String(http.StatusForbidden, gomock.Any())
+
+		assert.NoError(t, client.Reload(echo))
+		info, _ := client.Vb.Rules()
+		assert.Equal(t, active, info)
+	})
+
+	t.Run("reload when disabled returns 403", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+		client := validationBackend()
+		active, _ := client.Vb.Rules()
+
+		echo.EXPECT().String(http.StatusForbidden, "reloading the rules is disabled, enable it with the reload setting")
+
+		assert.NoError(t, client.Reload(echo))
+		info, _ := client.Vb.Rules()
+		assert.Equal(t, active, info)
+	})
+}
+
+func TestApiWrapper_GetRules(t *testing.T) {
+	t.Run("active rules", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+		client := validationBackend()
+		active, _ := client.Vb.Rules()
+
+		echo.EXPECT().JSON(http.StatusOK, rulesInfoFrom(active))
+
+		assert.NoError(t, client.GetRules(echo))
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		client := ApiWrapper{&pkg.Validator{}}
+
+		assert.Equal(t, pkg.ErrNotConfigured, client.GetRules(nil))
+	})
+}
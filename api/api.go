@@ -20,94 +20,187 @@
 package api
 
 import (
-	"io/ioutil"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
 	"github.com/sirupsen/logrus"
-	"github.com/thedevsaddam/gojsonq/v2"
 )
 
+// fhirVersionParameter is the name of the content type parameter that selects the FHIR version
+const fhirVersionParameter = "fhirVersion"
+
 // ApiWrapper wraps the Validator
 type ApiWrapper struct {
 	Vb *pkg.Validator
 }
 
-// Validate handles the Post /consent/validate REST call. It always returns a 200 code with an outcome.
+// Validate handles the Post /consent/validate REST call. It returns a 200 code with an outcome.
 // If invalid then a list of errors will be included.
-func (aw *ApiWrapper) Validate(ctx echo.Context) error {
-	buf, err := ioutil.ReadAll(ctx.Request().Body)
+// When the client accepts application/fhir+json, the outcome and the errors of the request are returned as FHIR OperationOutcome.
+func (aw *ApiWrapper) Validate(ctx echo.Context, params ValidateParams) error {
+	req := ctx.Request()
+	buf, err := aw.readBody(req)
+	if httpErr, ok := err.(*echo.HTTPError); ok && acceptsFHIR(req) {
+		return fhirJSON(ctx, httpErr.Code, operationOutcomeFromHTTPError(httpErr))
+	}
 	if err != nil {
 		logrus.Error(err.Error())
 		return err
 	}
 
-	valid, errors, err := aw.Vb.ValidateAgainstSchema(buf)
-
+	fhirVersion, err := fhirVersionFrom(req, params.FhirVersion)
 	if err != nil {
-		logrus.Error(err.Error())
-		return ctx.JSON(http.StatusOK, ValidationResponse{
-			Outcome: "invalid",
-			ValidationErrors: &[]ValidationError{
-				{
-					Type:    "syntax",
-					Message: err.Error(),
-				},
-			},
-		})
-	}
-
-	if !valid {
-		var validationErrors []ValidationError
-
-		for _, e := range errors {
-			validationErrors = append(validationErrors, ValidationError{Message: e, Type: "constraint"})
+		if acceptsFHIR(req) {
+			return fhirJSON(ctx, http.StatusBadRequest, operationOutcomeFromHTTPError(echo.NewHTTPError(http.StatusBadRequest, err.Error())))
 		}
-
-		return ctx.JSON(http.StatusOK, ValidationResponse{
-			Outcome:          "invalid",
-			ValidationErrors: &validationErrors,
-		})
+		return ctx.String(http.StatusBadRequest, err.Error())
 	}
 
-	simplifiedConsent, err := extractSimplifiedConsent(buf)
+	response, err := aw.validate(buf, fhirVersion)
 	if err != nil {
 		logrus.Error(err.Error())
-		return ctx.JSON(http.StatusOK, ValidationResponse{
-			Outcome: "invalid",
-			ValidationErrors: &[]ValidationError{
-				{
-					Type:    "syntax",
-					Message: err.Error(),
-				},
-			},
-		})
-	}
-
-	return ctx.JSON(http.StatusOK, ValidationResponse{
-		Outcome: "valid",
-		Consent: simplifiedConsent,
-	})
+		return err
+	}
+
+	if acceptsFHIR(req) {
+		return fhirJSON(ctx, http.StatusOK, operationOutcomeFrom(response))
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// readBody reads the request body within the maximum size and read timeout of the Validator and the lifetime of the request.
+// A body that is too large results in a 413, a body that is not received in time in a 408.
+// When the server is configured with ConfigureServer, the read timeout is set as read deadline on the connection of the request,
+// which also ends a body that stops sending.
+func (aw *ApiWrapper) readBody(req *http.Request) ([]byte, error) {
+	if maxSize := aw.Vb.Config.MaxSize; maxSize > 0 && req.ContentLength > int64(maxSize) {
+		return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("%s of %d bytes", pkg.ErrDocumentTooLarge, maxSize))
+	}
+
+	var body io.Reader = req.Body
+	// a HTTP/2 connection is shared by other requests, it can not get the deadline of this one
+	if conn, ok := req.Context().Value(connContextKey{}).(net.Conn); ok && req.ProtoMajor == 1 {
+		body = connBody{Reader: req.Body, conn: conn}
+	}
+
+	buf, err := aw.Vb.ReadDocumentContext(req.Context(), body)
+	switch {
+	case errors.Is(err, pkg.ErrDocumentTooLarge):
+		return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, pkg.ErrReadTimeout):
+		return nil, echo.NewHTTPError(http.StatusRequestTimeout, err.Error())
+	}
+	return buf, err
+}
+
+// connContextKey is the request context key of the connection a request is received on
+type connContextKey struct{}
+
+// ConfigureServer adds the connection of every request to the request context of the server, readBody uses it to end a request body
+// that is not received within the read timeout. It must be called before the server is started, an existing ConnContext is kept.
+func ConfigureServer(server *http.Server) {
+	connContext := server.ConnContext
+	server.ConnContext = func(ctx context.Context, conn net.Conn) context.Context {
+		if connContext != nil {
+			ctx = connContext(ctx, conn)
+		}
+		return context.WithValue(ctx, connContextKey{}, conn)
+	}
+}
+
+// connBody is a request body that gets a read deadline by setting it on the connection of the request
+type connBody struct {
+	io.Reader
+	conn net.Conn
 }
 
-func extractSimplifiedConsent(bytes []byte) (*SimplifiedConsent, error) {
-	jsonqFromString := jsonqFromString(string(bytes))
+func (b connBody) SetReadDeadline(t time.Time) error {
+	return b.conn.SetReadDeadline(t)
+}
 
-	as := pkg.ActorsFrom(jsonqFromString)
-	actors := make([]Identifier, len(as))
-	for i, a := range as {
-		actors[i] = Identifier(a)
+// fhirVersionFrom returns the FHIR version given by the fhirVersion query parameter or, when that is absent, by the fhirVersion parameter of the content type.
+// An empty version is returned when neither is given, the Validator then uses the configured version.
+func fhirVersionFrom(req *http.Request, fhirVersion *string) (string, error) {
+	var value string
+	if fhirVersion != nil {
+		value = *fhirVersion
+	} else if _, params, err := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType)); err == nil {
+		// media type parameter names are lower case
+		value = params[strings.ToLower(fhirVersionParameter)]
+	}
+	if value == "" {
+		return "", nil
 	}
+	return pkg.ParseFHIRVersion(value)
+}
 
-	return &SimplifiedConsent{
-		Subject:   Identifier(pkg.SubjectFrom(jsonqFromString)),
-		Custodian: Identifier(pkg.CustodianFrom(jsonqFromString)),
-		Actors:    actors,
-		Resources: pkg.ResourcesFrom(jsonqFromString),
-	}, nil
+// validate runs all validation stages for the given FHIR version: schema and Nuts profile, node policy and extraction of the simplified consent.
+// Errors in the document are part of the response, the returned error is only used for processing failures.
+func (aw *ApiWrapper) validate(buf []byte, fhirVersion string) (ValidationResponse, error) {
+	report, err := aw.Vb.ValidateFHIRVersion(buf, fhirVersion)
+	if err != nil {
+		return ValidationResponse{}, err
+	}
+
+	return validationResponseFrom(report), nil
 }
 
-func jsonqFromString(source string) *gojsonq.JSONQ {
-	return gojsonq.New().JSONString(source)
+// validationResponseFrom converts the report of the Validator to the API model
+func validationResponseFrom(report *pkg.ValidationReport) ValidationResponse {
+	response := ValidationResponse{Outcome: report.Outcome}
+	if report.FHIRVersion != "" {
+		fhirVersion := report.FHIRVersion
+		response.FhirVersion = &fhirVersion
+	}
+	if len(report.Errors) > 0 {
+		response.ValidationErrors = validationErrorsFrom(report.Errors)
+	}
+	if report.Consent != nil {
+		actors := make([]Identifier, len(report.Consent.Actors))
+		for i, a := range report.Consent.Actors {
+			actors[i] = Identifier(a)
+		}
+		response.Consent = &SimplifiedConsent{
+			Subject:   Identifier(report.Consent.Subject),
+			Custodian: Identifier(report.Consent.Custodian),
+			Actors:    actors,
+			Resources: report.Consent.Resources,
+		}
+	}
+	return response
+}
+
+// validationErrorsFrom converts the errors of the Validator to the API model
+func validationErrorsFrom(errors []pkg.ValidationError) *[]ValidationError {
+	validationErrors := make([]ValidationError, len(errors))
+
+	for i, e := range errors {
+		validationErrors[i] = ValidationError{
+			Code:     e.Code,
+			Message:  e.Message,
+			Pointer:  e.Pointer,
+			Severity: e.Severity,
+			Type:     e.Type,
+		}
+		if e.Expected != "" {
+			expected := e.Expected
+			validationErrors[i].Expected = &expected
+		}
+		if e.Actual != "" {
+			actual := e.Actual
+			validationErrors[i].Actual = &actual
+		}
+	}
+
+	return &validationErrors
 }
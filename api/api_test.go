@@ -20,16 +20,23 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
 	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
 	core "github.com/nuts-foundation/nuts-go-core"
 	"github.com/nuts-foundation/nuts-go-core/mock"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestDefaultValidationBackend_Validate(t *testing.T) {
@@ -49,7 +56,7 @@ func TestDefaultValidationBackend_Validate(t *testing.T) {
 		echo.EXPECT().Request().Return(request)
 		echo.EXPECT().JSON(http.StatusOK, gomock.Eq(emptyValidationError()))
 
-		err = client.Validate(echo)
+		err = client.Validate(echo, ValidateParams{})
 
 		if err != nil {
 			t.Errorf("Expected no error got [%s]", err.Error())
@@ -70,25 +77,281 @@ func TestDefaultValidationBackend_Validate(t *testing.T) {
 		echo.EXPECT().Request().Return(request)
 		echo.EXPECT().JSON(http.StatusOK, gomock.Eq(validationResult()))
 
-		err = client.Validate(echo)
+		err = client.Validate(echo, ValidateParams{})
 
 		if err != nil {
 			t.Errorf("Expected no error got [%s]", err.Error())
 		}
 	})
+
+	t.Run("Empty json returns OperationOutcome when FHIR is accepted", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		data, err := ioutil.ReadFile("../examples/empty.json")
+
+		request := &http.Request{
+			Header: http.Header{"Accept": []string{MIMEApplicationFHIRJSON}},
+			Body:   ioutil.NopCloser(bytes.NewReader(data)),
+		}
+
+		echo.EXPECT().Request().Return(request)
+		echo.EXPECT().Blob(http.StatusOK, MIMEApplicationFHIRJSON, gomock.Any()).DoAndReturn(func(code int, contentType string, body []byte) error {
+			outcome := OperationOutcome{}
+			if err := json.Unmarshal(body, &outcome); err != nil {
+				t.Fatal(err)
+			}
+			if assert.Len(t, outcome.Issue, 4) {
+				assert.Equal(t, "OperationOutcome", outcome.ResourceType)
+				assert.Equal(t, "required", outcome.Issue[3].Code)
+				assert.Equal(t, "error", outcome.Issue[3].Severity)
+				assert.Equal(t, []string{"Consent.resourceType"}, *outcome.Issue[3].Expression)
+				assert.Equal(t, "(root): resourceType is required", *outcome.Issue[3].Diagnostics)
+			}
+			return nil
+		})
+
+		err = client.Validate(echo, ValidateParams{})
+
+		if err != nil {
+			t.Errorf("Expected no error got [%s]", err.Error())
+		}
+	})
+
+	t.Run("Json not allowed by node policy returns 200 with policy error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		custodian := "urn:oid:2.16.840.1.113883.2.4.6.1:00000000"
+		validator := &pkg.Validator{}
+		validator.Config.Policy.Custodians = "urn:oid:2.16.840.1.113883.2.4.6.1:00000001"
+		validator.Configure()
+		client := ApiWrapper{validator}
+
+		json, err := ioutil.ReadFile("../examples/observation_consent.json")
+
+		request := &http.Request{
+			Body: ioutil.NopCloser(bytes.NewReader(json)),
+		}
+
+		echo.EXPECT().Request().Return(request)
+		echo.EXPECT().JSON(http.StatusOK, gomock.Eq(ValidationResponse{
+			FhirVersion: &r4,
+			Outcome:     "invalid",
+			ValidationErrors: &[]ValidationError{
+				{
+					Type:     "policy",
+					Code:     "policy.custodian-not-allowed",
+					Pointer:  "/organization/0/identifier",
+					Message:  "organization.0.identifier: custodian urn:oid:2.16.840.1.113883.2.4.6.1:00000000 is not allowed by this node",
+					Actual:   &custodian,
+					Severity: "error",
+				},
+			},
+		}))
+
+		err = client.Validate(echo, ValidateParams{})
+
+		if err != nil {
+			t.Errorf("Expected no error got [%s]", err.Error())
+		}
+	})
+
+	t.Run("R5 json selected by content type returns 200 with extracted body", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		json, _ := ioutil.ReadFile("../examples/r5_consent.json")
+
+		request := &http.Request{
+			Header: http.Header{"Content-Type": []string{"application/fhir+json; fhirVersion=5.0"}},
+			Body:   ioutil.NopCloser(bytes.NewReader(json)),
+		}
+
+		echo.EXPECT().Request().Return(request)
+		echo.EXPECT().JSON(http.StatusOK, gomock.Any()).DoAndReturn(func(code int, r ValidationResponse) error {
+			assert.Equal(t, "valid", r.Outcome)
+			assert.Equal(t, pkg.FHIRVersionR5, *r.FhirVersion)
+			if assert.NotNil(t, r.Consent) {
+				assert.Equal(t, Identifier("urn:oid:2.16.840.1.113883.2.4.6.3:999999990"), r.Consent.Subject)
+			}
+			return nil
+		})
+
+		err := client.Validate(echo, ValidateParams{})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("unsupported FHIR version returns 400", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		request := &http.Request{
+			Body: ioutil.NopCloser(bytes.NewReader([]byte("{}"))),
+		}
+		version := "1.0"
+
+		echo.EXPECT().Request().Return(request)
+		echo.EXPECT().String(http.StatusBadRequest, "unsupported FHIR version 1.0, use 3.0 (STU3), 4.0 (R4) or 5.0 (R5)")
+
+		err := client.Validate(echo, ValidateParams{FhirVersion: &version})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("unsupported FHIR version returns 400 OperationOutcome when FHIR is accepted", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		request := &http.Request{
+			Header: http.Header{"Accept": []string{MIMEApplicationFHIRJSON}},
+			Body:   ioutil.NopCloser(bytes.NewReader([]byte("{}"))),
+		}
+		version := "1.0"
+
+		echo.EXPECT().Request().Return(request)
+		echo.EXPECT().Blob(http.StatusBadRequest, MIMEApplicationFHIRJSON, gomock.Any()).DoAndReturn(func(code int, contentType string, body []byte) error {
+			outcome := OperationOutcome{}
+			if err := json.Unmarshal(body, &outcome); err != nil {
+				t.Fatal(err)
+			}
+			if assert.Len(t, outcome.Issue, 1) {
+				assert.Equal(t, "invalid", outcome.Issue[0].Code)
+				assert.Equal(t, "fatal", outcome.Issue[0].Severity)
+				assert.Equal(t, "unsupported FHIR version 1.0, use 3.0 (STU3), 4.0 (R4) or 5.0 (R5)", *outcome.Issue[0].Diagnostics)
+			}
+			return nil
+		})
+
+		err := client.Validate(echo, ValidateParams{FhirVersion: &version})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("body exceeding maximum size returns 413 OperationOutcome when FHIR is accepted", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+		client := validationBackend()
+		client.Vb.Config.MaxSize = 2
+
+		request := &http.Request{
+			Header:        http.Header{"Accept": []string{MIMEApplicationFHIRJSON}},
+			ContentLength: 3,
+			Body:          ioutil.NopCloser(bytes.NewReader([]byte("{ }"))),
+		}
+
+		echo.EXPECT().Request().Return(request)
+		echo.EXPECT().Blob(http.StatusRequestEntityTooLarge, MIMEApplicationFHIRJSON, gomock.Any()).DoAndReturn(func(code int, contentType string, body []byte) error {
+			outcome := OperationOutcome{}
+			if err := json.Unmarshal(body, &outcome); err != nil {
+				t.Fatal(err)
+			}
+			if assert.Len(t, outcome.Issue, 1) {
+				assert.Equal(t, "too-costly", outcome.Issue[0].Code)
+			}
+			return nil
+		})
+
+		err := client.Validate(echo, ValidateParams{})
+
+		assert.NoError(t, err)
+	})
 }
 
+func TestFhirVersionFrom(t *testing.T) {
+	t.Run("from content type", func(t *testing.T) {
+		version, err := fhirVersionFrom(&http.Request{Header: http.Header{"Content-Type": []string{"application/fhir+json;fhirVersion=5.0"}}}, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, pkg.FHIRVersionR5, version)
+	})
+
+	t.Run("query parameter before content type", func(t *testing.T) {
+		query := "STU3"
+		version, err := fhirVersionFrom(&http.Request{Header: http.Header{"Content-Type": []string{"application/fhir+json; fhirVersion=5.0"}}}, &query)
+
+		assert.NoError(t, err)
+		assert.Equal(t, pkg.FHIRVersionSTU3, version)
+	})
+
+	t.Run("none given", func(t *testing.T) {
+		version, err := fhirVersionFrom(&http.Request{Header: http.Header{"Content-Type": []string{"application/json"}}}, nil)
+
+		assert.NoError(t, err)
+		assert.Empty(t, version)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		query := "R6"
+		_, err := fhirVersionFrom(&http.Request{}, &query)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestRegisterHandlers(t *testing.T) {
+	client := validationBackend()
+	e := echo.New()
+	RegisterHandlers(e, &client)
+	r5, _ := ioutil.ReadFile("../examples/r5_consent.json")
+
+	t.Run("fhirVersion query parameter is passed to the handler", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/consent/validate?fhirVersion=5.0", bytes.NewReader(r5))
+		req.Header.Set(echo.HeaderContentType, "application/json")
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		response := ValidationResponse{}
+		if assert.Equal(t, http.StatusOK, rec.Code) && assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response)) {
+			assert.Equal(t, "valid", response.Outcome)
+			assert.Equal(t, pkg.FHIRVersionR5, *response.FhirVersion)
+		}
+	})
+}
+
+// r4 is the FHIR version of the responses
+var r4 = pkg.FHIRVersionR4
+
 func emptyValidationError() ValidationResponse {
 	return ValidationResponse{
-		Outcome: "invalid",
+		FhirVersion: &r4,
+		Outcome:     "invalid",
 		ValidationErrors: &[]ValidationError{
 			{
-				Type:    "constraint",
-				Message: "(root): Must validate one and only one schema (oneOf)",
+				Type:     "constraint",
+				Code:     "number_one_of",
+				Pointer:  "",
+				Message:  "(root): Must validate one and only one schema (oneOf)",
+				Severity: "error",
+			},
+			{
+				Type:     "constraint",
+				Code:     "required",
+				Pointer:  "/scope",
+				Message:  "(root): scope is required",
+				Severity: "error",
 			},
 			{
-				Type:    "constraint",
-				Message: "(root): resourceType is required",
+				Type:     "constraint",
+				Code:     "required",
+				Pointer:  "/category",
+				Message:  "(root): category is required",
+				Severity: "error",
+			},
+			{
+				Type:     "constraint",
+				Code:     "required",
+				Pointer:  "/resourceType",
+				Message:  "(root): resourceType is required",
+				Severity: "error",
 			},
 		},
 	}
@@ -102,12 +365,104 @@ func validationResult() ValidationResponse {
 			Resources: []string{"http://hl7.org/fhir/resource-types#Observation", fmt.Sprintf("urn:oid:%s:MEDICAL", core.NutsConsentClassesOID)},
 			Subject:   Identifier("urn:oid:2.16.840.1.113883.2.4.6.3:999999990"),
 		},
-		Outcome: "valid",
+		FhirVersion: &r4,
+		Outcome:     "valid",
+	}
+}
+
+func TestApiWrapper_readBody(t *testing.T) {
+	client := validationBackend()
+	client.Vb.Config.MaxSize = 2
+	client.Vb.Config.ReadTimeout = 1
+
+	t.Run("body within limits", func(t *testing.T) {
+		buf, err := client.readBody(&http.Request{Body: ioutil.NopCloser(bytes.NewReader([]byte("{}")))})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "{}", string(buf))
+	})
+
+	t.Run("content length exceeding maximum size returns 413", func(t *testing.T) {
+		_, err := client.readBody(&http.Request{ContentLength: 3, Body: ioutil.NopCloser(bytes.NewReader([]byte("{ }")))})
+
+		httpErr, ok := err.(*echo.HTTPError)
+		if assert.True(t, ok) {
+			assert.Equal(t, http.StatusRequestEntityTooLarge, httpErr.Code)
+		}
+	})
+
+	t.Run("body exceeding maximum size without content length returns 413", func(t *testing.T) {
+		_, err := client.readBody(&http.Request{ContentLength: -1, Body: ioutil.NopCloser(bytes.NewReader([]byte("{ }")))})
+
+		httpErr, ok := err.(*echo.HTTPError)
+		if assert.True(t, ok) {
+			assert.Equal(t, http.StatusRequestEntityTooLarge, httpErr.Code)
+		}
+	})
+
+	t.Run("slow body returns 408", func(t *testing.T) {
+		_, err := client.readBody(&http.Request{Body: ioutil.NopCloser(stalledReader{})})
+
+		httpErr, ok := err.(*echo.HTTPError)
+		if assert.True(t, ok) {
+			assert.Equal(t, http.StatusRequestTimeout, httpErr.Code)
+		}
+	})
+}
+
+func TestConfigureServer(t *testing.T) {
+	client := validationBackend()
+	client.Vb.Config.ReadTimeout = 1
+	e := echo.New()
+	ConfigureServer(e.Server)
+	RegisterHandlers(e, &client)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
 	}
+	e.Server.Handler = e
+	go e.Server.Serve(listener)
+	defer e.Server.Close()
+
+	t.Run("body that stops sending returns 408", func(t *testing.T) {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		// the client sends half of the body and then stalls
+		fmt.Fprint(conn, "POST /consent/validate HTTP/1.1\r\nHost: localhost\r\nContent-Type: application/json\r\nContent-Length: 100\r\n\r\n{\"resourceType\":")
+		conn.SetReadDeadline(time.Now().Add(4 * time.Second))
+		start := time.Now()
+		response, err := http.ReadResponse(bufio.NewReader(conn), nil)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusRequestTimeout, response.StatusCode)
+			assert.Less(t, int64(time.Since(start)), int64(3*time.Second))
+		}
+	})
+
+	t.Run("body within the timeout is validated", func(t *testing.T) {
+		response, err := http.Post("http://"+listener.Addr().String()+"/consent/validate", "application/json", bytes.NewReader([]byte("{}")))
+
+		if assert.NoError(t, err) {
+			defer response.Body.Close()
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+		}
+	})
 }
 
 func validationBackend() ApiWrapper {
-	client := pkg.Validator{}
+	client := &pkg.Validator{}
 	client.Configure()
-	return ApiWrapper{&client}
+	return ApiWrapper{client}
+}
+
+// stalledReader sends no data but returns between reads, TestConfigureServer covers a read that blocks
+type stalledReader struct{}
+
+func (stalledReader) Read(p []byte) (int, error) {
+	time.Sleep(100 * time.Millisecond)
+	return 0, nil
 }
@@ -0,0 +1,202 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInvalidBatch is returned when the body is not a json array, NDJSON stream or FHIR Bundle
+var ErrInvalidBatch = errors.New("batch must be a json array, NDJSON stream or FHIR Bundle")
+
+// maxNDJSONLine is the maximum size of a single record in a NDJSON stream
+const maxNDJSONLine = 16 * 1024 * 1024
+
+// batchEntry is a single consent record from a batch request
+type batchEntry struct {
+	fullUrl  string
+	resource []byte
+}
+
+// bundle is the part of the FHIR Bundle resource used for batch validation
+type bundle struct {
+	ResourceType string `json:"resourceType"`
+	Entry        []struct {
+		FullUrl  string          `json:"fullUrl,omitempty"`
+		Resource json.RawMessage `json:"resource"`
+	} `json:"entry"`
+}
+
+// ValidateBatch handles the Post /consent/validate/batch REST call. The body is a json array, NDJSON stream or FHIR Bundle of consent records.
+// It returns a 200 code with the outcome of every entry, a 400 code is returned when the batch itself can not be parsed.
+func (aw *ApiWrapper) ValidateBatch(ctx echo.Context, params ValidateBatchParams) error {
+	req := ctx.Request()
+	buf, err := aw.readBody(req)
+	if err != nil {
+		logrus.Error(err.Error())
+		return err
+	}
+
+	fhirVersion, err := fhirVersionFrom(req, params.FhirVersion)
+	if err != nil {
+		return ctx.String(http.StatusBadRequest, err.Error())
+	}
+
+	entries, err := splitBatch(req.Header.Get(echo.HeaderContentType), buf)
+	if err != nil {
+		return ctx.String(http.StatusBadRequest, err.Error())
+	}
+
+	response, err := aw.validateBatch(entries, fhirVersion)
+	if err != nil {
+		logrus.Error(err.Error())
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// validateBatch validates all entries concurrently for the FHIR version, the entries in the response have the same order as the input
+func (aw *ApiWrapper) validateBatch(entries []batchEntry, fhirVersion string) (BatchValidationResponse, error) {
+	results := make([]BatchValidationEntry, len(entries))
+	errs := make([]error, len(entries))
+
+	indices := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < runtime.NumCPU() && w < len(entries); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				response, err := aw.validate(entries[i].resource, fhirVersion)
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				results[i] = BatchValidationEntry{
+					Index:            i,
+					Outcome:          response.Outcome,
+					ValidationErrors: response.ValidationErrors,
+					Consent:          response.Consent,
+				}
+				if entries[i].fullUrl != "" {
+					fullUrl := entries[i].fullUrl
+					results[i].FullUrl = &fullUrl
+				}
+			}
+		}()
+	}
+
+	for i := range entries {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	response := BatchValidationResponse{Entries: results, Total: len(results)}
+	for i, r := range results {
+		if errs[i] != nil {
+			return BatchValidationResponse{}, errs[i]
+		}
+		if r.Outcome == "valid" {
+			response.Valid++
+		} else {
+			response.Invalid++
+		}
+	}
+
+	return response, nil
+}
+
+// splitBatch splits the body into separate consent records.
+// NDJSON is used when given as content type or when the body holds multiple objects. A single object that is not a Bundle is a batch of one.
+func splitBatch(contentType string, buf []byte) ([]batchEntry, error) {
+	if strings.Contains(contentType, "ndjson") {
+		return splitNDJSON(buf)
+	}
+
+	trimmed := bytes.TrimSpace(buf)
+	if len(trimmed) == 0 {
+		return nil, ErrInvalidBatch
+	}
+
+	switch trimmed[0] {
+	case '[':
+		var list []json.RawMessage
+		if err := json.Unmarshal(trimmed, &list); err != nil {
+			return nil, ErrInvalidBatch
+		}
+		entries := make([]batchEntry, len(list))
+		for i, l := range list {
+			entries[i] = batchEntry{resource: l}
+		}
+		return entries, nil
+	case '{':
+		var b bundle
+		if err := json.Unmarshal(trimmed, &b); err != nil {
+			return splitNDJSON(buf)
+		}
+		if b.ResourceType != "Bundle" {
+			return []batchEntry{{resource: trimmed}}, nil
+		}
+		entries := make([]batchEntry, len(b.Entry))
+		for i, e := range b.Entry {
+			entries[i] = batchEntry{fullUrl: e.FullUrl, resource: e.Resource}
+		}
+		return entries, nil
+	}
+
+	return nil, ErrInvalidBatch
+}
+
+// splitNDJSON returns every non empty line as entry
+func splitNDJSON(buf []byte) ([]batchEntry, error) {
+	var entries []batchEntry
+
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	scanner.Buffer(make([]byte, 64*1024), maxNDJSONLine)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		resource := make([]byte, len(line))
+		copy(resource, line)
+		entries = append(entries, batchEntry{resource: resource})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, ErrInvalidBatch
+	}
+	return entries, nil
+}
@@ -0,0 +1,125 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nuts-foundation/nuts-go-core/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiWrapper_ValidateBatch(t *testing.T) {
+	client := validationBackend()
+	consent, _ := ioutil.ReadFile("../examples/observation_consent.json")
+	compact := &bytes.Buffer{}
+	json.Compact(compact, consent)
+
+	call := func(t *testing.T, contentType string, body []byte) BatchValidationResponse {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		var response BatchValidationResponse
+		echo.EXPECT().Request().Return(&http.Request{
+			Header: http.Header{"Content-Type": []string{contentType}},
+			Body:   ioutil.NopCloser(bytes.NewReader(body)),
+		})
+		echo.EXPECT().JSON(http.StatusOK, gomock.Any()).DoAndReturn(func(code int, r interface{}) error {
+			response = r.(BatchValidationResponse)
+			return nil
+		})
+
+		if err := client.ValidateBatch(echo, ValidateBatchParams{}); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	t.Run("json array", func(t *testing.T) {
+		response := call(t, "application/json", []byte(fmt.Sprintf("[%s, {}, %s]", consent, consent)))
+
+		assert.Equal(t, 3, response.Total)
+		assert.Equal(t, 2, response.Valid)
+		assert.Equal(t, 1, response.Invalid)
+		for i, e := range response.Entries {
+			assert.Equal(t, i, e.Index)
+		}
+		assert.Equal(t, "valid", response.Entries[0].Outcome)
+		assert.NotNil(t, response.Entries[0].Consent)
+		assert.Equal(t, "invalid", response.Entries[1].Outcome)
+		assert.Len(t, *response.Entries[1].ValidationErrors, 4)
+	})
+
+	t.Run("NDJSON stream", func(t *testing.T) {
+		response := call(t, "application/x-ndjson", []byte(fmt.Sprintf("%s\n\n{\n%s\n", compact, compact)))
+
+		assert.Equal(t, 3, response.Total)
+		assert.Equal(t, 2, response.Valid)
+		assert.Equal(t, "syntax", (*response.Entries[1].ValidationErrors)[0].Type)
+	})
+
+	t.Run("Bundle", func(t *testing.T) {
+		response := call(t, "application/fhir+json", []byte(fmt.Sprintf(`{"resourceType": "Bundle", "type": "collection", "entry": [{"fullUrl": "urn:uuid:1", "resource": %s}]}`, consent)))
+
+		assert.Equal(t, 1, response.Total)
+		assert.Equal(t, 1, response.Valid)
+		assert.Equal(t, "urn:uuid:1", *response.Entries[0].FullUrl)
+	})
+
+	t.Run("unparseable batch returns 400", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		echo.EXPECT().Request().Return(&http.Request{Body: ioutil.NopCloser(bytes.NewReader([]byte("consent")))})
+		echo.EXPECT().String(http.StatusBadRequest, ErrInvalidBatch.Error())
+
+		assert.NoError(t, client.ValidateBatch(echo, ValidateBatchParams{}))
+	})
+}
+
+func TestSplitBatch(t *testing.T) {
+	t.Run("single object is batch of one", func(t *testing.T) {
+		entries, err := splitBatch("application/json", []byte("{\n  \"resourceType\": \"Consent\"\n}"))
+
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("multiple objects without content type are NDJSON", func(t *testing.T) {
+		entries, err := splitBatch("", []byte("{}\n{}\n"))
+
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+	})
+
+	t.Run("empty body", func(t *testing.T) {
+		_, err := splitBatch("application/json", []byte(" "))
+
+		assert.Equal(t, ErrInvalidBatch, err)
+	})
+}
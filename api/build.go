@@ -0,0 +1,91 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+// Build handles the Post /consent/build REST call.
+// It returns a 200 code with the FHIR Consent, a 400 code is returned when the request can not be parsed, is incomplete or the built record does not pass validation.
+func (aw *ApiWrapper) Build(ctx echo.Context) error {
+	buf, err := aw.readBody(ctx.Request())
+	if err != nil {
+		logrus.Error(err.Error())
+		return err
+	}
+
+	var request ConsentBuildRequest
+	if err := json.Unmarshal(buf, &request); err != nil {
+		return ctx.String(http.StatusBadRequest, err.Error())
+	}
+
+	consent, err := aw.Vb.BuildConsent(consentSpecFrom(request))
+	if err != nil {
+		return ctx.String(http.StatusBadRequest, err.Error())
+	}
+
+	return ctx.Blob(http.StatusOK, MIMEApplicationFHIRJSON, consent)
+}
+
+// consentSpecFrom converts the API model to the spec used by the builder
+func consentSpecFrom(request ConsentBuildRequest) pkg.ConsentSpec {
+	spec := pkg.ConsentSpec{
+		Subject:   string(request.Subject),
+		Custodian: string(request.Custodian),
+		Classes:   request.Resources,
+		Start:     request.Period.Start,
+		End:       request.Period.End,
+		Proof:     pkg.Attachment{ContentType: request.Proof.ContentType},
+	}
+	for _, a := range request.Actors {
+		spec.Actors = append(spec.Actors, pkg.Identifier(a))
+	}
+	if request.Performer != nil {
+		spec.Performer = string(*request.Performer)
+	}
+	if request.Actions != nil {
+		spec.Actions = *request.Actions
+	}
+	if request.VersionId != nil {
+		spec.Version = *request.VersionId
+	}
+	if request.LastUpdated != nil {
+		spec.LastUpdated = *request.LastUpdated
+	}
+	if request.Proof.Data != nil {
+		spec.Proof.Data = *request.Proof.Data
+	}
+	if request.Proof.Url != nil {
+		spec.Proof.URL = *request.Proof.Url
+	}
+	if request.Proof.Hash != nil {
+		spec.Proof.Hash = *request.Proof.Hash
+	}
+	if request.Proof.Title != nil {
+		spec.Proof.Title = *request.Proof.Title
+	}
+	return spec
+}
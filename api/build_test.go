@@ -0,0 +1,78 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nuts-foundation/nuts-go-core/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiWrapper_Build(t *testing.T) {
+	client := validationBackend()
+
+	t.Run("built consent is valid", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+		body := []byte(`{
+			"subject": "urn:oid:2.16.840.1.113883.2.4.6.3:999999990",
+			"custodian": "urn:oid:2.16.840.1.113883.2.4.6.1:00000000",
+			"actors": ["urn:oid:2.16.840.1.113883.2.4.6.1:00000007"],
+			"resources": ["urn:oid:1.3.6.1.4.1.54851.1:MEDICAL"],
+			"period": {"start": "2016-06-23T17:02:33+10:00"},
+			"proof": {"contentType": "application/pdf", "data": "dhklauHAELrlg78OLg==", "title": "Toestemming"}
+		}`)
+
+		var consent []byte
+		echo.EXPECT().Request().Return(&http.Request{Body: ioutil.NopCloser(bytes.NewReader(body))})
+		echo.EXPECT().Blob(http.StatusOK, MIMEApplicationFHIRJSON, gomock.Any()).DoAndReturn(func(code int, contentType string, b []byte) error {
+			consent = b
+			return nil
+		})
+
+		if err := client.Build(echo); err != nil {
+			t.Fatal(err)
+		}
+
+		response, err := client.validate(consent, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "valid", response.Outcome)
+		assert.Equal(t, []string{"urn:oid:1.3.6.1.4.1.54851.1:MEDICAL"}, response.Consent.Resources)
+	})
+
+	t.Run("incomplete request", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		echo.EXPECT().Request().Return(&http.Request{Body: ioutil.NopCloser(bytes.NewReader([]byte(`{"subject": "urn:oid:2.16.840.1.113883.2.4.6.3:999999990"}`)))})
+		echo.EXPECT().String(http.StatusBadRequest, gomock.Any())
+
+		if err := client.Build(echo); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+// GetClasses handles the Get /consent/classes REST call. It returns a 200 code with the class registry of the active rules.
+func (aw *ApiWrapper) GetClasses(ctx echo.Context) error {
+	registry, err := aw.Vb.Classes()
+	if err != nil {
+		logrus.Error(err.Error())
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, classRegistryFrom(registry))
+}
+
+// classRegistryFrom converts the class registry of the Validator to the API model
+func classRegistryFrom(registry pkg.ClassRegistry) ClassRegistry {
+	result := ClassRegistry{Classes: []ConsentClass{}, Systems: []ClassSystem{}}
+	for _, c := range registry.Classes {
+		class := ConsentClass{Class: c.Class, Display: c.Display}
+		if c.Description != "" {
+			description := c.Description
+			class.Description = &description
+		}
+		if len(c.ResourceTypes) > 0 {
+			resourceTypes := append([]string{}, c.ResourceTypes...)
+			class.ResourceTypes = &resourceTypes
+		}
+		if len(c.Profiles) > 0 {
+			profiles := append([]string{}, c.Profiles...)
+			class.Profiles = &profiles
+		}
+		result.Classes = append(result.Classes, class)
+	}
+	for _, s := range registry.Systems {
+		system := ClassSystem{System: s.System}
+		if s.Description != "" {
+			description := s.Description
+			system.Description = &description
+		}
+		if s.CodeIsResourceType {
+			codeIsResourceType := true
+			system.CodeIsResourceType = &codeIsResourceType
+		}
+		result.Systems = append(result.Systems, system)
+	}
+	return result
+}
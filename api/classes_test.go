@@ -0,0 +1,61 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	"github.com/nuts-foundation/nuts-go-core/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiWrapper_GetClasses(t *testing.T) {
+	t.Run("bundled registry", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+		client := validationBackend()
+
+		echo.EXPECT().JSON(http.StatusOK, gomock.Any()).DoAndReturn(func(code int, registry ClassRegistry) error {
+			if assert.Len(t, registry.Classes, 2) {
+				assert.Equal(t, "urn:oid:1.3.6.1.4.1.54851.1:MEDICAL", registry.Classes[0].Class)
+				assert.Equal(t, "Medical", registry.Classes[0].Display)
+				assert.Contains(t, *registry.Classes[0].ResourceTypes, "Observation")
+				assert.Nil(t, registry.Classes[0].Profiles)
+			}
+			if assert.Len(t, registry.Systems, 1) {
+				assert.Equal(t, "http://hl7.org/fhir/resource-types", registry.Systems[0].System)
+				assert.True(t, *registry.Systems[0].CodeIsResourceType)
+			}
+			return nil
+		})
+
+		assert.NoError(t, client.GetClasses(echo))
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		client := ApiWrapper{&pkg.Validator{}}
+
+		assert.Equal(t, pkg.ErrNotConfigured, client.GetClasses(nil))
+	})
+}
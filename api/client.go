@@ -0,0 +1,192 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+// HttpClient holds the server address and other basic settings for the http client
+type HttpClient struct {
+	ServerAddress string
+	Timeout       time.Duration
+}
+
+func (hb HttpClient) client() *Client {
+	url := hb.ServerAddress
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = fmt.Sprintf("http://%v", url)
+	}
+
+	response, err := NewClient(url)
+	if err != nil {
+		logrus.Panic(err)
+	}
+
+	return response
+}
+
+// ValidateAgainstSchemaConsentAt reads the consent record at the given location (on disk) and validates it at the remote node
+func (hb HttpClient) ValidateAgainstSchemaConsentAt(source string) (bool, []pkg.ValidationError, error) {
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return false, nil, err
+	}
+
+	return hb.ValidateAgainstSchema(data)
+}
+
+// ValidateAgainstSchema validates the consent record at the remote node.
+// The policy of the remote node is applied as well, policy errors are left out and returned by ValidateAgainstPolicy.
+func (hb HttpClient) ValidateAgainstSchema(json []byte) (bool, []pkg.ValidationError, error) {
+	report, err := hb.Validate(json)
+	if err != nil {
+		return false, nil, err
+	}
+
+	var schemaErrors []pkg.ValidationError
+	for _, e := range report.Errors {
+		if e.Type != pkg.TypePolicy {
+			schemaErrors = append(schemaErrors, e)
+		}
+	}
+	return len(schemaErrors) == 0, schemaErrors, nil
+}
+
+// ValidateAgainstPolicy checks the consent record against the policy of the remote node
+func (hb HttpClient) ValidateAgainstPolicy(json []byte) ([]pkg.ValidationError, error) {
+	report, err := hb.Validate(json)
+	if err != nil {
+		return nil, err
+	}
+
+	var policyErrors []pkg.ValidationError
+	for _, e := range report.Errors {
+		if e.Type == pkg.TypePolicy {
+			policyErrors = append(policyErrors, e)
+		}
+	}
+	return policyErrors, nil
+}
+
+// ValidateFile reads the consent record at the given location (on disk) and runs all validation stages at the remote node
+func (hb HttpClient) ValidateFile(source string) (*pkg.ValidationReport, error) {
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return hb.Validate(data)
+}
+
+// ValidateReader sends the consent record read from the reader to the remote node and runs all validation stages there
+func (hb HttpClient) ValidateReader(reader io.Reader) (*pkg.ValidationReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), hb.Timeout)
+	defer cancel()
+
+	res, err := hb.client().ValidateWithBody(ctx, &ValidateParams{}, "application/json", reader)
+	if err != nil {
+		logrus.Error(fmt.Sprintf("error while validating consent record at remote node: %v", err))
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		err = fmt.Errorf("remote node returned %d: %s", res.StatusCode, string(body))
+		logrus.Error(err.Error())
+		return nil, err
+	}
+
+	var response ValidationResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	return reportFrom(response), nil
+}
+
+// Validate runs all validation stages on the consent record at the remote node
+func (hb HttpClient) Validate(json []byte) (*pkg.ValidationReport, error) {
+	return hb.ValidateReader(bytes.NewReader(json))
+}
+
+// reportFrom converts the API model back to the report of the Validator
+func reportFrom(response ValidationResponse) *pkg.ValidationReport {
+	report := &pkg.ValidationReport{
+		Outcome: response.Outcome,
+		Errors:  validationErrorsTo(response.ValidationErrors),
+	}
+	if response.FhirVersion != nil {
+		report.FHIRVersion = *response.FhirVersion
+	}
+	if response.Consent != nil {
+		actors := make([]pkg.Identifier, len(response.Consent.Actors))
+		for i, a := range response.Consent.Actors {
+			actors[i] = pkg.Identifier(a)
+		}
+		report.Consent = &pkg.SimplifiedConsent{
+			Subject:   pkg.Identifier(response.Consent.Subject),
+			Custodian: pkg.Identifier(response.Consent.Custodian),
+			Actors:    actors,
+			Resources: response.Consent.Resources,
+		}
+	}
+	return report
+}
+
+// validationErrorsTo converts the API model back to the errors of the Validator
+func validationErrorsTo(errors *[]ValidationError) []pkg.ValidationError {
+	if errors == nil {
+		return nil
+	}
+
+	result := make([]pkg.ValidationError, len(*errors))
+	for i, e := range *errors {
+		result[i] = pkg.ValidationError{
+			Type:     e.Type,
+			Code:     e.Code,
+			Pointer:  e.Pointer,
+			Message:  e.Message,
+			Severity: e.Severity,
+		}
+		if e.Expected != nil {
+			result[i].Expected = *e.Expected
+		}
+		if e.Actual != nil {
+			result[i].Actual = *e.Actual
+		}
+	}
+	return result
+}
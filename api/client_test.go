@@ -0,0 +1,132 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpClient(t *testing.T) {
+	validator := &pkg.Validator{}
+	validator.Config.Policy.Contenttypes = "application/pdf"
+	validator.Configure()
+	e := echo.New()
+	RegisterHandlers(e, &ApiWrapper{Vb: validator})
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	client := HttpClient{ServerAddress: server.URL, Timeout: time.Second}
+	consent, _ := ioutil.ReadFile("../examples/observation_consent.json")
+
+	t.Run("valid consent", func(t *testing.T) {
+		valid, errs, err := client.ValidateAgainstSchema(consent)
+
+		assert.NoError(t, err)
+		assert.True(t, valid)
+		assert.Empty(t, errs)
+	})
+
+	t.Run("consent at location", func(t *testing.T) {
+		valid, _, err := client.ValidateAgainstSchemaConsentAt("../examples/observation_consent.json")
+
+		assert.NoError(t, err)
+		assert.True(t, valid)
+	})
+
+	t.Run("invalid consent", func(t *testing.T) {
+		valid, errs, err := client.ValidateAgainstSchema([]byte("{}"))
+
+		assert.NoError(t, err)
+		assert.False(t, valid)
+		assert.Len(t, errs, 4)
+		assert.Equal(t, pkg.TypeConstraint, errs[0].Type)
+	})
+
+	t.Run("policy errors are returned by ValidateAgainstPolicy", func(t *testing.T) {
+		json := []byte(strings.Replace(string(consent), `"contentType": "application/pdf"`, `"contentType": "image/png"`, 1))
+
+		valid, errs, err := client.ValidateAgainstSchema(json)
+		assert.NoError(t, err)
+		assert.True(t, valid)
+		assert.Empty(t, errs)
+
+		errs, err = client.ValidateAgainstPolicy(json)
+		assert.NoError(t, err)
+		if assert.Len(t, errs, 1) {
+			assert.Equal(t, "policy.content-type-not-allowed", errs[0].Code)
+			assert.Equal(t, "image/png", errs[0].Actual)
+		}
+	})
+
+	t.Run("report of valid consent", func(t *testing.T) {
+		report, err := client.Validate(consent)
+
+		assert.NoError(t, err)
+		assert.True(t, report.Valid())
+		assert.Empty(t, report.Errors)
+		if assert.NotNil(t, report.Consent) {
+			assert.Equal(t, pkg.Identifier("urn:oid:2.16.840.1.113883.2.4.6.3:999999990"), report.Consent.Subject)
+			assert.NotEmpty(t, report.Consent.Actors)
+		}
+	})
+
+	t.Run("report of consent at location", func(t *testing.T) {
+		report, err := client.ValidateFile("../examples/observation_consent.json")
+
+		assert.NoError(t, err)
+		assert.Equal(t, pkg.OutcomeValid, report.Outcome)
+	})
+
+	t.Run("report of invalid consent from reader", func(t *testing.T) {
+		report, err := client.ValidateReader(strings.NewReader("{}"))
+
+		assert.NoError(t, err)
+		assert.False(t, report.Valid())
+		assert.Len(t, report.Errors, 4)
+		assert.Nil(t, report.Consent)
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer s.Close()
+
+		_, _, err := HttpClient{ServerAddress: s.URL, Timeout: time.Second}.ValidateAgainstSchema(consent)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("address without scheme", func(t *testing.T) {
+		valid, _, err := HttpClient{ServerAddress: server.Listener.Addr().String(), Timeout: time.Second}.ValidateAgainstSchema(consent)
+
+		assert.NoError(t, err)
+		assert.True(t, valid)
+	})
+}
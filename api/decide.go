@@ -0,0 +1,128 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+// Decide handles the Post /consent/decide REST call. Every consent record in the request is validated before it is used for the decision.
+// It returns a 200 code with the decision, a 400 code is returned when the request can not be parsed or a consent record is invalid.
+func (aw *ApiWrapper) Decide(ctx echo.Context) error {
+	buf, err := aw.readBody(ctx.Request())
+	if err != nil {
+		logrus.Error(err.Error())
+		return err
+	}
+
+	var request DecisionRequest
+	if err := json.Unmarshal(buf, &request); err != nil {
+		return ctx.String(http.StatusBadRequest, err.Error())
+	}
+
+	consents := make([]*pkg.Consent, len(request.Consents))
+	for i, c := range request.Consents {
+		if consents[i], err = aw.validConsent(fmt.Sprintf("consents.%d", i), c); err != nil {
+			return recordError(ctx, err)
+		}
+	}
+
+	decision, err := pkg.Decide(consents, decisionRequestFrom(request))
+	if err != nil {
+		return ctx.String(http.StatusBadRequest, err.Error())
+	}
+
+	response := DecisionResponse{Decision: decision.Decision, Provisions: []MatchedProvision{}}
+	for _, p := range decision.Provisions {
+		response.Provisions = append(response.Provisions, MatchedProvision{
+			Consent:   p.Consent,
+			Provision: p.Provision,
+			Type:      p.Type,
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// decisionRequestFrom converts the API model to the request used by pkg.Decide
+func decisionRequestFrom(request DecisionRequest) pkg.DecisionRequest {
+	r := pkg.DecisionRequest{
+		Custodian: string(request.Custodian),
+		Subject:   string(request.Subject),
+		Actor:     pkg.Identifier(request.Actor),
+		Class:     request.Class,
+	}
+	if request.Action != nil {
+		r.Action = *request.Action
+	}
+	if request.Timestamp != nil {
+		r.Timestamp = *request.Timestamp
+	}
+	return r
+}
+
+// invalidRecordError is returned when a consent record that is part of a request does not pass validation
+type invalidRecordError struct {
+	field   string
+	message string
+}
+
+func (e invalidRecordError) Error() string {
+	return fmt.Sprintf("%s: consent record is invalid: %s", e.field, e.message)
+}
+
+// validConsent runs all validation stages on a consent record that is part of a request and returns the parsed record.
+// An invalidRecordError is returned when the record is invalid, other errors are processing failures.
+func (aw *ApiWrapper) validConsent(field string, record map[string]interface{}) (*pkg.Consent, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	// records in requests are parsed into the R4 model
+	response, err := aw.validate(data, pkg.FHIRVersionR4)
+	if err != nil {
+		return nil, err
+	}
+	if response.Outcome != "valid" {
+		return nil, invalidRecordError{field: field, message: (*response.ValidationErrors)[0].Message}
+	}
+
+	consent, err := pkg.ParseConsent(data)
+	if err != nil {
+		return nil, invalidRecordError{field: field, message: err.Error()}
+	}
+	return consent, nil
+}
+
+// recordError responds with a 400 code for an invalidRecordError, other errors are returned as is
+func recordError(ctx echo.Context, err error) error {
+	if _, ok := err.(invalidRecordError); ok {
+		return ctx.String(http.StatusBadRequest, err.Error())
+	}
+	logrus.Error(err.Error())
+	return err
+}
@@ -0,0 +1,105 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nuts-foundation/nuts-go-core/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiWrapper_Decide(t *testing.T) {
+	client := validationBackend()
+	consent, _ := ioutil.ReadFile("../examples/observation_consent.json")
+	request := func(consents string, timestamp string) []byte {
+		return []byte(fmt.Sprintf(`{
+			"custodian": "urn:oid:2.16.840.1.113883.2.4.6.1:00000000",
+			"subject": "urn:oid:2.16.840.1.113883.2.4.6.3:999999990",
+			"actor": "urn:oid:2.16.840.1.113883.2.4.6.1:00000007",
+			"class": "urn:oid:1.3.6.1.4.1.54851.1:MEDICAL",
+			"timestamp": "%s",
+			"consents": [%s]
+		}`, timestamp, consents))
+	}
+
+	t.Run("permit", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		echo.EXPECT().Request().Return(&http.Request{Body: ioutil.NopCloser(bytes.NewReader(request(string(consent), "2016-06-23T17:10:00+10:00")))})
+		echo.EXPECT().JSON(http.StatusOK, DecisionResponse{
+			Decision:   "permit",
+			Provisions: []MatchedProvision{{Consent: 0, Provision: "provision.provision.0", Type: "permit"}},
+		})
+
+		if err := client.Decide(echo); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("deny outside period", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		echo.EXPECT().Request().Return(&http.Request{Body: ioutil.NopCloser(bytes.NewReader(request(string(consent), "2020-01-01T00:00:00Z")))})
+		echo.EXPECT().JSON(http.StatusOK, DecisionResponse{Decision: "deny", Provisions: []MatchedProvision{}})
+
+		if err := client.Decide(echo); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("invalid consent record", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		echo.EXPECT().Request().Return(&http.Request{Body: ioutil.NopCloser(bytes.NewReader(request("{}", "2020-01-01T00:00:00Z")))})
+		echo.EXPECT().String(http.StatusBadRequest, gomock.Any()).DoAndReturn(func(code int, s string) error {
+			assert.Contains(t, s, "consents.0: consent record is invalid")
+			return nil
+		})
+
+		if err := client.Decide(echo); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("broken request", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		echo.EXPECT().Request().Return(&http.Request{Body: ioutil.NopCloser(bytes.NewReader([]byte("{")))})
+		echo.EXPECT().String(http.StatusBadRequest, gomock.Any())
+
+		if err := client.Decide(echo); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
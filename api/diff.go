@@ -0,0 +1,78 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+// Diff handles the Post /consent/diff REST call. Both records are validated before they are compared.
+// It returns a 200 code with the changes, a 400 code is returned when the request can not be parsed or one of the records is invalid.
+func (aw *ApiWrapper) Diff(ctx echo.Context) error {
+	buf, err := aw.readBody(ctx.Request())
+	if err != nil {
+		logrus.Error(err.Error())
+		return err
+	}
+
+	var request DiffRequest
+	if err := json.Unmarshal(buf, &request); err != nil {
+		return ctx.String(http.StatusBadRequest, err.Error())
+	}
+
+	old, err := aw.validConsent("old", request.Old)
+	if err != nil {
+		return recordError(ctx, err)
+	}
+	new, err := aw.validConsent("new", request.New)
+	if err != nil {
+		return recordError(ctx, err)
+	}
+
+	changes, err := pkg.Diff(old, new)
+	if err != nil {
+		return ctx.String(http.StatusBadRequest, err.Error())
+	}
+
+	return ctx.JSON(http.StatusOK, DiffResponse{Changes: changesFrom(changes)})
+}
+
+// changesFrom converts the changes of pkg to the API model
+func changesFrom(changes []pkg.Change) []Change {
+	result := []Change{}
+	for _, c := range changes {
+		change := Change{Type: c.Type}
+		if c.Old != "" {
+			old := c.Old
+			change.Old = &old
+		}
+		if c.New != "" {
+			n := c.New
+			change.New = &n
+		}
+		result = append(result, change)
+	}
+	return result
+}
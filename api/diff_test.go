@@ -0,0 +1,72 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nuts-foundation/nuts-go-core/mock"
+)
+
+func TestApiWrapper_Diff(t *testing.T) {
+	client := validationBackend()
+	old, _ := ioutil.ReadFile("../examples/observation_consent.json")
+	new := strings.Replace(string(old), `"value": "00000007"`, `"value": "00000008"`, 1)
+	body := func(old string, new string) *http.Request {
+		return &http.Request{Body: ioutil.NopCloser(bytes.NewReader([]byte(fmt.Sprintf(`{"old": %s, "new": %s}`, old, new))))}
+	}
+
+	t.Run("changed actor", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+		added := "urn:oid:2.16.840.1.113883.2.4.6.1:00000008"
+		removed := "urn:oid:2.16.840.1.113883.2.4.6.1:00000007"
+
+		echo.EXPECT().Request().Return(body(string(old), new))
+		echo.EXPECT().JSON(http.StatusOK, DiffResponse{Changes: []Change{
+			{Type: "actor-added", New: &added},
+			{Type: "actor-removed", Old: &removed},
+		}})
+
+		if err := client.Diff(echo); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("invalid record", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		echo.EXPECT().Request().Return(body("{}", new))
+		echo.EXPECT().String(http.StatusBadRequest, gomock.Any())
+
+		if err := client.Diff(echo); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
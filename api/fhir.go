@@ -0,0 +1,315 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+// MIMEApplicationFHIRJSON is the media type for FHIR resources in json
+const MIMEApplicationFHIRJSON = "application/fhir+json"
+
+// modes of the $validate operation
+const (
+	modeCreate = "create"
+	modeUpdate = "update"
+	modeDelete = "delete"
+)
+
+// parameters is the part of the FHIR Parameters resource used by the $validate operation
+type parameters struct {
+	ResourceType string `json:"resourceType"`
+	Parameter    []struct {
+		Name           string          `json:"name"`
+		Resource       json.RawMessage `json:"resource,omitempty"`
+		ValueCode      string          `json:"valueCode,omitempty"`
+		ValueUri       string          `json:"valueUri,omitempty"`
+		ValueCanonical string          `json:"valueCanonical,omitempty"`
+	} `json:"parameter"`
+}
+
+// validateRequest holds the input of the $validate operation
+type validateRequest struct {
+	resource    []byte
+	mode        string
+	profile     string
+	fhirVersion string
+}
+
+// ValidateOperation handles the standard FHIR POST /Consent/$validate operation.
+// The body is either a Consent or a Parameters resource with resource, mode and profile parameters.
+// The FHIR version is selected by the fhirVersion parameter of the content type or query.
+// The result is always an OperationOutcome.
+func (aw *ApiWrapper) ValidateOperation(ctx echo.Context, params ValidateOperationParams) error {
+	req := ctx.Request()
+	buf, err := aw.readBody(req)
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		return fhirJSON(ctx, httpErr.Code, operationOutcomeFromHTTPError(httpErr))
+	}
+	if err != nil {
+		logrus.Error(err.Error())
+		return err
+	}
+
+	request, err := parseValidateRequest(buf)
+	if err == nil {
+		request.fhirVersion, err = fhirVersionFrom(req, params.FhirVersion)
+	}
+	if err != nil {
+		return fhirJSON(ctx, http.StatusBadRequest, operationOutcomeFrom(ValidationResponse{
+			Outcome:          "invalid",
+			ValidationErrors: validationErrorsFrom([]pkg.ValidationError{pkg.SyntaxError(err)}),
+		}))
+	}
+
+	// deleting a consent record is always allowed from a validation perspective
+	if request.mode == modeDelete && len(request.resource) == 0 {
+		return fhirJSON(ctx, http.StatusOK, operationOutcomeFrom(ValidationResponse{Outcome: "valid"}))
+	}
+
+	response, err := aw.validate(request.resource, request.fhirVersion)
+	if err != nil {
+		logrus.Error(err.Error())
+		return err
+	}
+
+	if request.mode == modeUpdate {
+		response = requireID(request.resource, response)
+	}
+
+	// a record that can not be parsed can not be checked against the profile, the syntax errors are reported
+	if request.profile != "" && !hasSyntaxError(response) {
+		errs, err := aw.Vb.ValidateAgainstProfile(request.resource, request.fhirVersion, request.profile)
+		if errors.Is(err, pkg.ErrProfileNotApplicable) {
+			diagnostics := err.Error()
+			return fhirJSON(ctx, http.StatusBadRequest, OperationOutcome{
+				ResourceType: "OperationOutcome",
+				Issue: []OperationOutcomeIssue{{
+					Severity:    pkg.SeverityError,
+					Code:        "not-supported",
+					Diagnostics: &diagnostics,
+				}},
+			})
+		}
+		if err != nil {
+			logrus.Error(err.Error())
+			return err
+		}
+		if len(errs) > 0 {
+			all := *validationErrorsFrom(errs)
+			if response.ValidationErrors != nil {
+				all = append(*response.ValidationErrors, all...)
+			}
+			response = ValidationResponse{Outcome: "invalid", FhirVersion: response.FhirVersion, ValidationErrors: &all}
+		}
+	}
+
+	return fhirJSON(ctx, http.StatusOK, operationOutcomeFrom(response))
+}
+
+// requireID makes a valid response invalid when the record has no id, a record is updated by its id
+func requireID(resource []byte, response ValidationResponse) ValidationResponse {
+	if response.Outcome != "valid" {
+		return response
+	}
+	var record struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(resource, &record); err != nil || record.ID != "" {
+		return response
+	}
+	return ValidationResponse{
+		Outcome:     "invalid",
+		FhirVersion: response.FhirVersion,
+		ValidationErrors: &[]ValidationError{{
+			Type:     pkg.TypeConstraint,
+			Code:     "update.id-required",
+			Pointer:  "/id",
+			Message:  "(root): id is required when validating for update",
+			Severity: pkg.SeverityError,
+		}},
+	}
+}
+
+// hasSyntaxError returns true if the record of the response could not be parsed
+func hasSyntaxError(response ValidationResponse) bool {
+	if response.ValidationErrors == nil {
+		return false
+	}
+	for _, e := range *response.ValidationErrors {
+		if e.Type == pkg.TypeSyntax {
+			return true
+		}
+	}
+	return false
+}
+
+// parseValidateRequest reads the body of the $validate operation
+func parseValidateRequest(buf []byte) (validateRequest, error) {
+	var params parameters
+	if err := json.Unmarshal(buf, &params); err != nil {
+		return validateRequest{}, err
+	}
+
+	if params.ResourceType != "Parameters" {
+		return validateRequest{resource: buf}, nil
+	}
+
+	request := validateRequest{}
+	for _, p := range params.Parameter {
+		switch p.Name {
+		case "resource":
+			request.resource = p.Resource
+		case "mode":
+			request.mode = p.ValueCode
+		case "profile":
+			request.profile = p.ValueUri
+			if request.profile == "" {
+				request.profile = p.ValueCanonical
+			}
+		}
+	}
+
+	switch request.mode {
+	case "", modeCreate, modeUpdate:
+		if len(request.resource) == 0 {
+			return validateRequest{}, fmt.Errorf("parameter resource is required for mode %s", request.mode)
+		}
+	case modeDelete:
+	default:
+		return validateRequest{}, fmt.Errorf("unknown mode %s, supported modes: %s, %s, %s", request.mode, modeCreate, modeUpdate, modeDelete)
+	}
+
+	return request, nil
+}
+
+// acceptsFHIR returns true if the client asks for a FHIR resource as response
+func acceptsFHIR(req *http.Request) bool {
+	return strings.Contains(req.Header.Get(echo.HeaderAccept), MIMEApplicationFHIRJSON)
+}
+
+// fhirJSON writes the FHIR resource with the FHIR media type
+func fhirJSON(ctx echo.Context, code int, resource interface{}) error {
+	bytes, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+	return ctx.Blob(code, MIMEApplicationFHIRJSON, bytes)
+}
+
+// operationOutcomeFrom converts a ValidationResponse to a FHIR OperationOutcome.
+// A valid response results in a single informational issue since an OperationOutcome requires at least one.
+func operationOutcomeFrom(response ValidationResponse) OperationOutcome {
+	outcome := OperationOutcome{ResourceType: "OperationOutcome"}
+
+	if response.ValidationErrors == nil || len(*response.ValidationErrors) == 0 {
+		diagnostics := "the consent record is " + response.Outcome
+		outcome.Issue = []OperationOutcomeIssue{{
+			Severity:    "information",
+			Code:        "informational",
+			Diagnostics: &diagnostics,
+		}}
+		return outcome
+	}
+
+	for _, e := range *response.ValidationErrors {
+		diagnostics := e.Message
+		issue := OperationOutcomeIssue{
+			Severity:    e.Severity,
+			Code:        issueType(e),
+			Diagnostics: &diagnostics,
+		}
+		if e.Type != pkg.TypeSyntax {
+			issue.Expression = &[]string{expressionFrom(e.Pointer)}
+		}
+		outcome.Issue = append(outcome.Issue, issue)
+	}
+
+	return outcome
+}
+
+// operationOutcomeFromHTTPError converts an error of the request to a FHIR OperationOutcome with a single fatal issue,
+// a bad request is invalid, a body that is too large is too-costly and a body that is not received in time a timeout
+func operationOutcomeFromHTTPError(httpErr *echo.HTTPError) OperationOutcome {
+	code := "exception"
+	switch httpErr.Code {
+	case http.StatusBadRequest:
+		code = "invalid"
+	case http.StatusRequestEntityTooLarge:
+		code = "too-costly"
+	case http.StatusRequestTimeout:
+		code = "timeout"
+	}
+	diagnostics := fmt.Sprint(httpErr.Message)
+	return OperationOutcome{
+		ResourceType: "OperationOutcome",
+		Issue: []OperationOutcomeIssue{{
+			Severity:    pkg.SeverityFatal,
+			Code:        code,
+			Diagnostics: &diagnostics,
+		}},
+	}
+}
+
+// issueType maps a ValidationError to a code from the FHIR IssueType value set
+func issueType(e ValidationError) string {
+	switch {
+	case e.Type == pkg.TypeSyntax:
+		return "structure"
+	case e.Type == pkg.TypePolicy || e.Type == pkg.TypeVersion:
+		return "business-rule"
+	case e.Code == "required" || strings.HasSuffix(e.Code, "-required"):
+		return "required"
+	case e.Code == "enum" || e.Code == "const" || e.Code == "invalid_type" || strings.HasSuffix(e.Code, "-invalid"):
+		return "value"
+	case strings.HasSuffix(e.Code, "-forbidden") || e.Code == "additional_property_not_allowed":
+		return "structure"
+	}
+	return "invariant"
+}
+
+// expressionFrom converts a JSON Pointer to a FHIRPath expression: /organization/0/identifier -> Consent.organization[0].identifier
+func expressionFrom(pointer string) string {
+	var expression strings.Builder
+	expression.WriteString("Consent")
+
+	if pointer == "" {
+		return expression.String()
+	}
+
+	for _, segment := range strings.Split(pointer[1:], "/") {
+		segment = strings.Replace(strings.Replace(segment, "~1", "/", -1), "~0", "~", -1)
+		if _, err := strconv.Atoi(segment); err == nil {
+			expression.WriteString("[" + segment + "]")
+		} else {
+			expression.WriteString("." + segment)
+		}
+	}
+	return expression.String()
+}
@@ -0,0 +1,260 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nuts-foundation/nuts-go-core/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpressionFrom(t *testing.T) {
+	assert.Equal(t, "Consent", expressionFrom(""))
+	assert.Equal(t, "Consent.patient.display", expressionFrom("/patient/display"))
+	assert.Equal(t, "Consent.organization[0].identifier", expressionFrom("/organization/0/identifier"))
+}
+
+func TestOperationOutcomeFrom(t *testing.T) {
+	t.Run("valid response has informational issue", func(t *testing.T) {
+		outcome := operationOutcomeFrom(validationResult())
+
+		assert.Equal(t, "OperationOutcome", outcome.ResourceType)
+		assert.Len(t, outcome.Issue, 1)
+		assert.Equal(t, "information", outcome.Issue[0].Severity)
+		assert.Equal(t, "informational", outcome.Issue[0].Code)
+	})
+
+	t.Run("issue types", func(t *testing.T) {
+		outcome := operationOutcomeFrom(ValidationResponse{
+			Outcome: "invalid",
+			ValidationErrors: &[]ValidationError{
+				{Type: "syntax", Code: "syntax", Severity: "fatal", Message: "unexpected EOF"},
+				{Type: "constraint", Code: "nuts.patient.display-forbidden", Pointer: "/patient/display", Severity: "error"},
+				{Type: "constraint", Code: "nuts.patient.identifier.system-invalid", Pointer: "/patient/identifier/system", Severity: "error"},
+				{Type: "policy", Code: "policy.custodian-not-allowed", Pointer: "/organization/0/identifier", Severity: "error"},
+			},
+		})
+
+		assert.Equal(t, "structure", outcome.Issue[0].Code)
+		assert.Equal(t, "fatal", outcome.Issue[0].Severity)
+		assert.Nil(t, outcome.Issue[0].Expression)
+		assert.Equal(t, "structure", outcome.Issue[1].Code)
+		assert.Equal(t, "value", outcome.Issue[2].Code)
+		assert.Equal(t, "business-rule", outcome.Issue[3].Code)
+		assert.Equal(t, []string{"Consent.organization[0].identifier"}, *outcome.Issue[3].Expression)
+	})
+}
+
+func TestApiWrapper_ValidateOperation(t *testing.T) {
+	client := validationBackend()
+	consent, _ := ioutil.ReadFile("../examples/observation_consent.json")
+
+	call := func(t *testing.T, body []byte, expectedStatus int) OperationOutcome {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		outcome := OperationOutcome{}
+		echo.EXPECT().Request().Return(&http.Request{Body: ioutil.NopCloser(bytes.NewReader(body))})
+		echo.EXPECT().Blob(expectedStatus, MIMEApplicationFHIRJSON, gomock.Any()).DoAndReturn(func(code int, contentType string, body []byte) error {
+			return json.Unmarshal(body, &outcome)
+		})
+
+		if err := client.ValidateOperation(echo, ValidateOperationParams{}); err != nil {
+			t.Fatal(err)
+		}
+		return outcome
+	}
+
+	parametersWith := func(params ...string) []byte {
+		return []byte(fmt.Sprintf(`{"resourceType": "Parameters", "parameter": [%s]}`, strings.Join(params, ",")))
+	}
+
+	t.Run("bare valid resource", func(t *testing.T) {
+		outcome := call(t, consent, http.StatusOK)
+
+		assert.Len(t, outcome.Issue, 1)
+		assert.Equal(t, "information", outcome.Issue[0].Severity)
+	})
+
+	t.Run("bare invalid resource", func(t *testing.T) {
+		outcome := call(t, []byte(`{}`), http.StatusOK)
+
+		assert.Len(t, outcome.Issue, 4)
+		assert.Equal(t, "error", outcome.Issue[0].Severity)
+	})
+
+	t.Run("Parameters with resource and mode create", func(t *testing.T) {
+		outcome := call(t, parametersWith(
+			fmt.Sprintf(`{"name": "resource", "resource": %s}`, consent),
+			`{"name": "mode", "valueCode": "create"}`,
+		), http.StatusOK)
+
+		assert.Len(t, outcome.Issue, 1)
+		assert.Equal(t, "information", outcome.Issue[0].Severity)
+	})
+
+	t.Run("Parameters with mode update requires id", func(t *testing.T) {
+		outcome := call(t, parametersWith(
+			fmt.Sprintf(`{"name": "resource", "resource": %s}`, consent),
+			`{"name": "mode", "valueCode": "update"}`,
+		), http.StatusOK)
+
+		assert.Len(t, outcome.Issue, 1)
+		assert.Equal(t, "required", outcome.Issue[0].Code)
+		assert.Equal(t, []string{"Consent.id"}, *outcome.Issue[0].Expression)
+	})
+
+	t.Run("Parameters with mode delete without resource", func(t *testing.T) {
+		outcome := call(t, parametersWith(`{"name": "mode", "valueCode": "delete"}`), http.StatusOK)
+
+		assert.Equal(t, "information", outcome.Issue[0].Severity)
+	})
+
+	t.Run("Parameters with profile validates against the profile", func(t *testing.T) {
+		outcome := call(t, parametersWith(
+			fmt.Sprintf(`{"name": "resource", "resource": %s}`, consent),
+			`{"name": "profile", "valueUri": "http://nuts.nl/fhir/StructureDefinition/nuts-consent"}`,
+		), http.StatusOK)
+
+		assert.Len(t, outcome.Issue, 1)
+		assert.Equal(t, "information", outcome.Issue[0].Severity)
+	})
+
+	t.Run("Parameters with profile reports violations of the profile", func(t *testing.T) {
+		withoutDateTime := strings.Replace(string(consent), `"dateTime": "2016-06-23T17:02:33+10:00",`, "", 1)
+
+		outcome := call(t, parametersWith(
+			fmt.Sprintf(`{"name": "resource", "resource": %s}`, withoutDateTime),
+			`{"name": "profile", "valueCanonical": "http://nuts.nl/fhir/StructureDefinition/nuts-consent"}`,
+		), http.StatusOK)
+
+		var diagnostics []string
+		for _, issue := range outcome.Issue {
+			diagnostics = append(diagnostics, *issue.Diagnostics)
+		}
+		assert.Contains(t, diagnostics, "(root): dateTime is required")
+		assert.Contains(t, diagnostics, "(root): dateTime is required by profile http://nuts.nl/fhir/StructureDefinition/nuts-consent")
+	})
+
+	t.Run("Parameters with unknown profile returns 400", func(t *testing.T) {
+		outcome := call(t, parametersWith(
+			fmt.Sprintf(`{"name": "resource", "resource": %s}`, consent),
+			`{"name": "profile", "valueUri": "http://example.com/StructureDefinition/other"}`,
+		), http.StatusBadRequest)
+
+		assert.Len(t, outcome.Issue, 1)
+		assert.Equal(t, "error", outcome.Issue[0].Severity)
+		assert.Equal(t, "not-supported", outcome.Issue[0].Code)
+		assert.Equal(t, "profile can not be applied: profile http://example.com/StructureDefinition/other is not known by this node", *outcome.Issue[0].Diagnostics)
+	})
+
+	t.Run("Parameters with unknown mode returns 400", func(t *testing.T) {
+		outcome := call(t, parametersWith(
+			fmt.Sprintf(`{"name": "resource", "resource": %s}`, consent),
+			`{"name": "mode", "valueCode": "patch"}`,
+		), http.StatusBadRequest)
+
+		assert.Equal(t, "fatal", outcome.Issue[0].Severity)
+		assert.Equal(t, "unknown mode patch, supported modes: create, update, delete", *outcome.Issue[0].Diagnostics)
+	})
+
+	t.Run("Parameters without resource returns 400", func(t *testing.T) {
+		outcome := call(t, parametersWith(`{"name": "mode", "valueCode": "create"}`), http.StatusBadRequest)
+
+		assert.Equal(t, "structure", outcome.Issue[0].Code)
+	})
+
+	t.Run("body exceeding maximum size returns 413 with OperationOutcome", func(t *testing.T) {
+		limited := validationBackend()
+		limited.Vb.Config.MaxSize = 2
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		echo.EXPECT().Request().Return(&http.Request{Body: ioutil.NopCloser(bytes.NewReader(consent))})
+		echo.EXPECT().Blob(http.StatusRequestEntityTooLarge, MIMEApplicationFHIRJSON, gomock.Any()).DoAndReturn(func(code int, contentType string, body []byte) error {
+			outcome := OperationOutcome{}
+			assert.NoError(t, json.Unmarshal(body, &outcome))
+			if assert.Len(t, outcome.Issue, 1) {
+				assert.Equal(t, "fatal", outcome.Issue[0].Severity)
+				assert.Equal(t, "too-costly", outcome.Issue[0].Code)
+				assert.Equal(t, "document exceeds the maximum size of 2 bytes", *outcome.Issue[0].Diagnostics)
+			}
+			return nil
+		})
+
+		assert.NoError(t, limited.ValidateOperation(echo, ValidateOperationParams{}))
+	})
+
+	t.Run("slow body returns 408 with OperationOutcome", func(t *testing.T) {
+		limited := validationBackend()
+		limited.Vb.Config.ReadTimeout = 1
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		echo.EXPECT().Request().Return(&http.Request{Body: ioutil.NopCloser(stalledReader{})})
+		echo.EXPECT().Blob(http.StatusRequestTimeout, MIMEApplicationFHIRJSON, gomock.Any()).DoAndReturn(func(code int, contentType string, body []byte) error {
+			outcome := OperationOutcome{}
+			assert.NoError(t, json.Unmarshal(body, &outcome))
+			if assert.Len(t, outcome.Issue, 1) {
+				assert.Equal(t, "timeout", outcome.Issue[0].Code)
+			}
+			return nil
+		})
+
+		assert.NoError(t, limited.ValidateOperation(echo, ValidateOperationParams{}))
+	})
+}
+
+func TestRequireID(t *testing.T) {
+	t.Run("valid record without id is invalid for update", func(t *testing.T) {
+		response := requireID([]byte(`{"resourceType": "Consent"}`), validationResult())
+
+		assert.Equal(t, "invalid", response.Outcome)
+		if assert.NotNil(t, response.FhirVersion) {
+			assert.Equal(t, r4, *response.FhirVersion)
+		}
+		if assert.NotNil(t, response.ValidationErrors) && assert.Len(t, *response.ValidationErrors, 1) {
+			assert.Equal(t, "update.id-required", (*response.ValidationErrors)[0].Code)
+		}
+	})
+
+	t.Run("valid record with id stays valid", func(t *testing.T) {
+		response := requireID([]byte(`{"resourceType": "Consent", "id": "1"}`), validationResult())
+
+		assert.Equal(t, "valid", response.Outcome)
+	})
+
+	t.Run("invalid record is left as is", func(t *testing.T) {
+		response := requireID([]byte(`{}`), emptyValidationError())
+
+		assert.Equal(t, emptyValidationError(), response)
+	})
+}
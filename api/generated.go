@@ -4,12 +4,244 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
+// BatchValidationEntry defines model for BatchValidationEntry.
+type BatchValidationEntry struct {
+
+	// Simplified consent record
+	Consent *SimplifiedConsent `json:"consent,omitempty"`
+
+	// fullUrl of the Bundle entry, only present for Bundle input
+	FullUrl *string `json:"fullUrl,omitempty"`
+
+	// Zero based position of the entry in the array, NDJSON stream or Bundle
+	Index            int                `json:"index"`
+	Outcome          string             `json:"outcome"`
+	ValidationErrors *[]ValidationError `json:"validationErrors,omitempty"`
+}
+
+// BatchValidationResponse defines model for BatchValidationResponse.
+type BatchValidationResponse struct {
+	Entries []BatchValidationEntry `json:"entries"`
+
+	// Number of invalid entries
+	Invalid int `json:"invalid"`
+
+	// Number of entries in the batch
+	Total int `json:"total"`
+
+	// Number of valid entries
+	Valid int `json:"valid"`
+}
+
+// Change defines model for Change.
+type Change struct {
+
+	// New value, absent for removals and a period without end
+	New *string `json:"new,omitempty"`
+
+	// Old value, absent for additions and a period without end
+	Old  *string `json:"old,omitempty"`
+	Type string  `json:"type"`
+}
+
+// ClassRegistry defines model for ClassRegistry.
+type ClassRegistry struct {
+	Classes []ConsentClass `json:"classes"`
+	Systems []ClassSystem  `json:"systems"`
+}
+
+// ClassSystem defines model for ClassSystem.
+type ClassSystem struct {
+
+	// true when a class of the system covers the FHIR resource type given by its code
+	CodeIsResourceType *bool   `json:"codeIsResourceType,omitempty"`
+	Description        *string `json:"description,omitempty"`
+	System             string  `json:"system"`
+}
+
+// ConsentBuildRequest defines model for ConsentBuildRequest.
+type ConsentBuildRequest struct {
+
+	// Actions from http://terminology.hl7.org/CodeSystem/consentaction, defaults to access
+	Actions *[]string    `json:"actions,omitempty"`
+	Actors  []Identifier `json:"actors"`
+
+	// Generic identifier used for representing BSN, agbcode, etc. It's always constructed as an URN followed by a colon (:) and then the identifying value of the given URN
+	Custodian Identifier `json:"custodian"`
+
+	// meta.lastUpdated, defaults to now
+	LastUpdated *time.Time `json:"lastUpdated,omitempty"`
+
+	// Generic identifier used for representing BSN, agbcode, etc. It's always constructed as an URN followed by a colon (:) and then the identifying value of the given URN
+	Performer *Identifier `json:"performer,omitempty"`
+
+	// Period of the consent, end is optional
+	Period Period `json:"period"`
+
+	// The document proving the consent, becomes the sourceAttachment
+	Proof Proof `json:"proof"`
+
+	// Classes of data as urn:oid:system:code or system#code
+	Resources []string `json:"resources"`
+
+	// Generic identifier used for representing BSN, agbcode, etc. It's always constructed as an URN followed by a colon (:) and then the identifying value of the given URN
+	Subject Identifier `json:"subject"`
+
+	// meta.versionId, defaults to 1
+	VersionId *int `json:"versionId,omitempty"`
+}
+
+// ConsentClass defines model for ConsentClass.
+type ConsentClass struct {
+
+	// Class of data as system and code combined
+	Class       string  `json:"class"`
+	Description *string `json:"description,omitempty"`
+	Display     string  `json:"display"`
+
+	// urls of the FHIR profiles the class covers
+	Profiles *[]string `json:"profiles,omitempty"`
+
+	// FHIR resource types the class covers
+	ResourceTypes *[]string `json:"resourceTypes,omitempty"`
+}
+
+// DecisionRequest defines model for DecisionRequest.
+type DecisionRequest struct {
+
+	// Action from http://terminology.hl7.org/CodeSystem/consentaction, defaults to access
+	Action *string `json:"action,omitempty"`
+
+	// Generic identifier used for representing BSN, agbcode, etc. It's always constructed as an URN followed by a colon (:) and then the identifying value of the given URN
+	Actor Identifier `json:"actor"`
+
+	// Class of data as system and code combined, eg: urn:oid:1.3.6.1.4.1.54851.1:MEDICAL
+	Class string `json:"class"`
+
+	// FHIR Consent records to evaluate, of several versions of a record (same id) only the one with the highest versionId is evaluated
+	Consents []map[string]interface{} `json:"consents"`
+
+	// Generic identifier used for representing BSN, agbcode, etc. It's always constructed as an URN followed by a colon (:) and then the identifying value of the given URN
+	Custodian Identifier `json:"custodian"`
+
+	// Generic identifier used for representing BSN, agbcode, etc. It's always constructed as an URN followed by a colon (:) and then the identifying value of the given URN
+	Subject Identifier `json:"subject"`
+
+	// Moment of access, defaults to now
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// DecisionResponse defines model for DecisionResponse.
+type DecisionResponse struct {
+	Decision string `json:"decision"`
+
+	// Provisions that matched the request, both permit and deny
+	Provisions []MatchedProvision `json:"provisions"`
+}
+
+// DiffRequest defines model for DiffRequest.
+type DiffRequest struct {
+
+	// The new FHIR Consent record
+	New map[string]interface{} `json:"new"`
+
+	// The old FHIR Consent record
+	Old map[string]interface{} `json:"old"`
+}
+
+// DiffResponse defines model for DiffResponse.
+type DiffResponse struct {
+	Changes []Change `json:"changes"`
+}
+
 // Identifier defines model for Identifier.
 type Identifier string
 
+// MatchedProvision defines model for MatchedProvision.
+type MatchedProvision struct {
+
+	// Zero based index of the consent record in the request
+	Consent int `json:"consent"`
+
+	// Location of the provision in the consent record, eg: provision.provision.0, or policyRule for an OPTOUT
+	Provision string `json:"provision"`
+	Type      string `json:"type"`
+}
+
+// OperationOutcome defines model for OperationOutcome.
+type OperationOutcome struct {
+	Issue []OperationOutcomeIssue `json:"issue"`
+
+	// Always OperationOutcome
+	ResourceType string `json:"resourceType"`
+}
+
+// OperationOutcomeIssue defines model for OperationOutcomeIssue.
+type OperationOutcomeIssue struct {
+
+	// FHIR IssueType, eg: structure, required, value, invariant or business-rule
+	Code string `json:"code"`
+
+	// Human readable description of the issue
+	Diagnostics *string `json:"diagnostics,omitempty"`
+
+	// FHIRPath expressions of the elements the issue applies to
+	Expression *[]string `json:"expression,omitempty"`
+	Severity   string    `json:"severity"`
+}
+
+// Period defines model for Period.
+type Period struct {
+	End   *time.Time `json:"end,omitempty"`
+	Start time.Time  `json:"start"`
+}
+
+// Proof defines model for Proof.
+type Proof struct {
+
+	// Mime type of the document, eg: application/pdf
+	ContentType string `json:"contentType"`
+
+	// Base64 encoded document
+	Data *string `json:"data,omitempty"`
+
+	// Base64 encoded SHA-1 of the document
+	Hash  *string `json:"hash,omitempty"`
+	Title *string `json:"title,omitempty"`
+
+	// Location of the document
+	Url *string `json:"url,omitempty"`
+}
+
+// RulesInfo defines model for RulesInfo.
+type RulesInfo struct {
+
+	// FHIR versions with a Consent schema
+	FhirVersions []string `json:"fhirVersions"`
+
+	// sha256 of the schemas, profiles and policy config
+	Hash     string    `json:"hash"`
+	LoadedAt time.Time `json:"loadedAt"`
+
+	// urls of the loaded StructureDefinitions
+	Profiles []string `json:"profiles"`
+
+	// 1 for the rules loaded at startup, incremented by every reload
+	Version int `json:"version"`
+}
+
 // SimplifiedConsent defines model for SimplifiedConsent.
 type SimplifiedConsent struct {
 	Actors []Identifier `json:"actors"`
@@ -27,10 +259,25 @@ type SimplifiedConsent struct {
 // ValidationError defines model for ValidationError.
 type ValidationError struct {
 
+	// The value that was found at the pointer, if known
+	Actual *string `json:"actual,omitempty"`
+
+	// Stable machine readable error code, eg: required, enum or nuts.patient.display-forbidden
+	Code string `json:"code"`
+
+	// The value that was expected at the pointer, if known
+	Expected *string `json:"expected,omitempty"`
+
 	// The actual error
 	Message string `json:"message"`
 
-	// Type of error: syntax (json is broken), constraint (json is not a valid fhir resource), policy (current Nuts node settings do not allow this record)
+	// JSON Pointer (RFC 6901) to the offending field, an empty string points to the whole document
+	Pointer string `json:"pointer"`
+
+	// Severity of the error: fatal (document could not be processed) or error
+	Severity string `json:"severity"`
+
+	// Type of error: syntax (json is broken), constraint (json is not a valid fhir resource), policy (current Nuts node settings do not allow this record), version (record is not a valid update of the previous version)
 	Type string `json:"type"`
 }
 
@@ -38,22 +285,883 @@ type ValidationError struct {
 type ValidationResponse struct {
 
 	// Simplified consent record
-	Consent          *SimplifiedConsent `json:"consent,omitempty"`
+	Consent *SimplifiedConsent `json:"consent,omitempty"`
+
+	// FHIR version the record is validated for, eg: 3.0 (STU3), 4.0 (R4) or 5.0 (R5)
+	FhirVersion      *string            `json:"fhirVersion,omitempty"`
 	Outcome          string             `json:"outcome"`
 	ValidationErrors *[]ValidationError `json:"validationErrors,omitempty"`
 }
 
+// BuildJSONBody defines parameters for Build.
+type BuildJSONBody ConsentBuildRequest
+
+// DecideJSONBody defines parameters for Decide.
+type DecideJSONBody DecisionRequest
+
+// VersionValidationRequest defines model for VersionValidationRequest.
+type VersionValidationRequest struct {
+
+	// The new version of the FHIR Consent record
+	Current map[string]interface{} `json:"current"`
+
+	// The previous version of the FHIR Consent record
+	Previous map[string]interface{} `json:"previous"`
+}
+
+// VersionValidationResponse defines model for VersionValidationResponse.
+type VersionValidationResponse struct {
+
+	// Changes the new version introduces
+	Changes          []Change           `json:"changes"`
+	Outcome          string             `json:"outcome"`
+	ValidationErrors *[]ValidationError `json:"validationErrors,omitempty"`
+}
+
+// DiffJSONBody defines parameters for Diff.
+type DiffJSONBody DiffRequest
+
+// ValidateOperationParams defines parameters for ValidateOperation.
+type ValidateOperationParams struct {
+
+	// FHIR version of the consent records: 3.0, 4.0 or 5.0, or STU3, R4 or R5. Takes precedence over the fhirVersion parameter of the content type, eg: application/fhir+json; fhirVersion=3.0. Without either the configured version is used. An unsupported version results in a 400.
+	FhirVersion *string `json:"fhirVersion,omitempty"`
+}
+
+// ValidateOperationJSONBody defines parameters for ValidateOperation.
+type ValidateOperationJSONBody map[string]interface{}
+
+// ValidateBatchParams defines parameters for ValidateBatch.
+type ValidateBatchParams struct {
+
+	// FHIR version of the consent records: 3.0, 4.0 or 5.0, or STU3, R4 or R5. Takes precedence over the fhirVersion parameter of the content type, eg: application/fhir+json; fhirVersion=3.0. Without either the configured version is used. An unsupported version results in a 400.
+	FhirVersion *string `json:"fhirVersion,omitempty"`
+}
+
+// ValidateBatchJSONBody defines parameters for ValidateBatch.
+type ValidateBatchJSONBody map[string]interface{}
+
+// ValidateVersionJSONBody defines parameters for ValidateVersion.
+type ValidateVersionJSONBody VersionValidationRequest
+
+// ValidateParams defines parameters for Validate.
+type ValidateParams struct {
+
+	// FHIR version of the consent records: 3.0, 4.0 or 5.0, or STU3, R4 or R5. Takes precedence over the fhirVersion parameter of the content type, eg: application/fhir+json; fhirVersion=3.0. Without either the configured version is used. An unsupported version results in a 400.
+	FhirVersion *string `json:"fhirVersion,omitempty"`
+}
+
 // ValidateJSONBody defines parameters for Validate.
 type ValidateJSONBody string
 
+// BuildRequestBody defines body for Build for application/json ContentType.
+type BuildJSONRequestBody BuildJSONBody
+
+// DecideRequestBody defines body for Decide for application/json ContentType.
+type DecideJSONRequestBody DecideJSONBody
+
+// DiffRequestBody defines body for Diff for application/json ContentType.
+type DiffJSONRequestBody DiffJSONBody
+
 // ValidateRequestBody defines body for Validate for application/json ContentType.
 type ValidateJSONRequestBody ValidateJSONBody
 
+// ValidateOperationRequestBody defines body for ValidateOperation for application/json ContentType.
+type ValidateOperationJSONRequestBody ValidateOperationJSONBody
+
+// ValidateBatchRequestBody defines body for ValidateBatch for application/json ContentType.
+type ValidateBatchJSONRequestBody ValidateBatchJSONBody
+
+// ValidateVersionRequestBody defines body for ValidateVersion for application/json ContentType.
+type ValidateVersionJSONRequestBody ValidateVersionJSONBody
+
+// RequestEditorFn  is the function signature for the RequestEditor callback function
+type RequestEditorFn func(req *http.Request, ctx context.Context) error
+
+// Doer performs HTTP requests.
+//
+// The standard http.Client implements this interface.
+type HttpRequestDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client which conforms to the OpenAPI3 specification for this service.
+type Client struct {
+	// The endpoint of the server conforming to this interface, with scheme,
+	// https://api.deepmap.com for example.
+	Server string
+
+	// Doer for performing requests, typically a *http.Client with any
+	// customized settings, such as certificate chains.
+	Client HttpRequestDoer
+
+	// A callback for modifying requests which are generated before sending over
+	// the network.
+	RequestEditor RequestEditorFn
+}
+
+// ClientOption allows setting custom parameters during construction
+type ClientOption func(*Client) error
+
+// Creates a new Client, with reasonable defaults
+func NewClient(server string, opts ...ClientOption) (*Client, error) {
+	// create a client with sane default values
+	client := Client{
+		Server: server,
+	}
+	// mutate client and add all optional params
+	for _, o := range opts {
+		if err := o(&client); err != nil {
+			return nil, err
+		}
+	}
+	// ensure the server URL always has a trailing slash
+	if !strings.HasSuffix(client.Server, "/") {
+		client.Server += "/"
+	}
+	// create httpClient, if not already present
+	if client.Client == nil {
+		client.Client = http.DefaultClient
+	}
+	return &client, nil
+}
+
+// WithHTTPClient allows overriding the default Doer, which is
+// automatically created using http.Client. This is useful for tests.
+func WithHTTPClient(doer HttpRequestDoer) ClientOption {
+	return func(c *Client) error {
+		c.Client = doer
+		return nil
+	}
+}
+
+// WithRequestEditorFn allows setting up a callback function, which will be
+// called right before sending the request. This can be used to mutate the request.
+func WithRequestEditorFn(fn RequestEditorFn) ClientOption {
+	return func(c *Client) error {
+		c.RequestEditor = fn
+		return nil
+	}
+}
+
+// The interface specification for the client above.
+type ClientInterface interface {
+	// ValidateOperation request  with any body
+	ValidateOperationWithBody(ctx context.Context, params *ValidateOperationParams, contentType string, body io.Reader) (*http.Response, error)
+
+	ValidateOperation(ctx context.Context, params *ValidateOperationParams, body ValidateOperationJSONRequestBody) (*http.Response, error)
+
+	// Reload request
+	Reload(ctx context.Context) (*http.Response, error)
+
+	// GetRules request
+	GetRules(ctx context.Context) (*http.Response, error)
+
+	// Build request  with any body
+	BuildWithBody(ctx context.Context, contentType string, body io.Reader) (*http.Response, error)
+
+	Build(ctx context.Context, body BuildJSONRequestBody) (*http.Response, error)
+
+	// GetClasses request
+	GetClasses(ctx context.Context) (*http.Response, error)
+
+	// Decide request  with any body
+	DecideWithBody(ctx context.Context, contentType string, body io.Reader) (*http.Response, error)
+
+	Decide(ctx context.Context, body DecideJSONRequestBody) (*http.Response, error)
+
+	// Diff request  with any body
+	DiffWithBody(ctx context.Context, contentType string, body io.Reader) (*http.Response, error)
+
+	Diff(ctx context.Context, body DiffJSONRequestBody) (*http.Response, error)
+
+	// Validate request  with any body
+	ValidateWithBody(ctx context.Context, params *ValidateParams, contentType string, body io.Reader) (*http.Response, error)
+
+	Validate(ctx context.Context, params *ValidateParams, body ValidateJSONRequestBody) (*http.Response, error)
+
+	// ValidateBatch request  with any body
+	ValidateBatchWithBody(ctx context.Context, params *ValidateBatchParams, contentType string, body io.Reader) (*http.Response, error)
+
+	ValidateBatch(ctx context.Context, params *ValidateBatchParams, body ValidateBatchJSONRequestBody) (*http.Response, error)
+
+	// ValidateVersion request  with any body
+	ValidateVersionWithBody(ctx context.Context, contentType string, body io.Reader) (*http.Response, error)
+
+	ValidateVersion(ctx context.Context, body ValidateVersionJSONRequestBody) (*http.Response, error)
+}
+
+func (c *Client) ValidateOperationWithBody(ctx context.Context, params *ValidateOperationParams, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := NewValidateOperationRequestWithBody(c.Server, params, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) ValidateOperation(ctx context.Context, params *ValidateOperationParams, body ValidateOperationJSONRequestBody) (*http.Response, error) {
+	req, err := NewValidateOperationRequest(c.Server, params, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) Reload(ctx context.Context) (*http.Response, error) {
+	req, err := NewReloadRequest(c.Server)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) GetRules(ctx context.Context) (*http.Response, error) {
+	req, err := NewGetRulesRequest(c.Server)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) BuildWithBody(ctx context.Context, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := NewBuildRequestWithBody(c.Server, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) Build(ctx context.Context, body BuildJSONRequestBody) (*http.Response, error) {
+	req, err := NewBuildRequest(c.Server, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) GetClasses(ctx context.Context) (*http.Response, error) {
+	req, err := NewGetClassesRequest(c.Server)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) DecideWithBody(ctx context.Context, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := NewDecideRequestWithBody(c.Server, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) Decide(ctx context.Context, body DecideJSONRequestBody) (*http.Response, error) {
+	req, err := NewDecideRequest(c.Server, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) DiffWithBody(ctx context.Context, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := NewDiffRequestWithBody(c.Server, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) Diff(ctx context.Context, body DiffJSONRequestBody) (*http.Response, error) {
+	req, err := NewDiffRequest(c.Server, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) ValidateWithBody(ctx context.Context, params *ValidateParams, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := NewValidateRequestWithBody(c.Server, params, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) Validate(ctx context.Context, params *ValidateParams, body ValidateJSONRequestBody) (*http.Response, error) {
+	req, err := NewValidateRequest(c.Server, params, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) ValidateBatchWithBody(ctx context.Context, params *ValidateBatchParams, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := NewValidateBatchRequestWithBody(c.Server, params, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) ValidateBatch(ctx context.Context, params *ValidateBatchParams, body ValidateBatchJSONRequestBody) (*http.Response, error) {
+	req, err := NewValidateBatchRequest(c.Server, params, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) ValidateVersionWithBody(ctx context.Context, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := NewValidateVersionRequestWithBody(c.Server, contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+func (c *Client) ValidateVersion(ctx context.Context, body ValidateVersionJSONRequestBody) (*http.Response, error) {
+	req, err := NewValidateVersionRequest(c.Server, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.RequestEditor != nil {
+		err = c.RequestEditor(req, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.Do(req)
+}
+
+// NewValidateOperationRequest calls the generic ValidateOperation builder with application/json body
+func NewValidateOperationRequest(server string, params *ValidateOperationParams, body ValidateOperationJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewValidateOperationRequestWithBody(server, params, "application/json", bodyReader)
+}
+
+// NewValidateOperationRequestWithBody generates requests for ValidateOperation with any type of body
+func NewValidateOperationRequestWithBody(server string, params *ValidateOperationParams, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	queryUrl, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	basePath := fmt.Sprintf("/Consent/$validate")
+	if basePath[0] == '/' {
+		basePath = basePath[1:]
+	}
+
+	queryUrl, err = queryUrl.Parse(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	queryValues := queryUrl.Query()
+
+	if params.FhirVersion != nil {
+
+		queryValues.Add("fhirVersion", *params.FhirVersion)
+
+	}
+
+	queryUrl.RawQuery = queryValues.Encode()
+
+	req, err := http.NewRequest("POST", queryUrl.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+	return req, nil
+}
+
+// NewReloadRequest generates requests for Reload
+func NewReloadRequest(server string) (*http.Request, error) {
+	var err error
+
+	queryUrl, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	basePath := fmt.Sprintf("/admin/reload")
+	if basePath[0] == '/' {
+		basePath = basePath[1:]
+	}
+
+	queryUrl, err = queryUrl.Parse(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", queryUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewGetRulesRequest generates requests for GetRules
+func NewGetRulesRequest(server string) (*http.Request, error) {
+	var err error
+
+	queryUrl, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	basePath := fmt.Sprintf("/admin/rules")
+	if basePath[0] == '/' {
+		basePath = basePath[1:]
+	}
+
+	queryUrl, err = queryUrl.Parse(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewBuildRequest calls the generic Build builder with application/json body
+func NewBuildRequest(server string, body BuildJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewBuildRequestWithBody(server, "application/json", bodyReader)
+}
+
+// NewBuildRequestWithBody generates requests for Build with any type of body
+func NewBuildRequestWithBody(server string, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	queryUrl, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	basePath := fmt.Sprintf("/consent/build")
+	if basePath[0] == '/' {
+		basePath = basePath[1:]
+	}
+
+	queryUrl, err = queryUrl.Parse(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", queryUrl.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+	return req, nil
+}
+
+// NewGetClassesRequest generates requests for GetClasses
+func NewGetClassesRequest(server string) (*http.Request, error) {
+	var err error
+
+	queryUrl, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	basePath := fmt.Sprintf("/consent/classes")
+	if basePath[0] == '/' {
+		basePath = basePath[1:]
+	}
+
+	queryUrl, err = queryUrl.Parse(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", queryUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// NewDecideRequest calls the generic Decide builder with application/json body
+func NewDecideRequest(server string, body DecideJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewDecideRequestWithBody(server, "application/json", bodyReader)
+}
+
+// NewDecideRequestWithBody generates requests for Decide with any type of body
+func NewDecideRequestWithBody(server string, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	queryUrl, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	basePath := fmt.Sprintf("/consent/decide")
+	if basePath[0] == '/' {
+		basePath = basePath[1:]
+	}
+
+	queryUrl, err = queryUrl.Parse(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", queryUrl.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+	return req, nil
+}
+
+// NewDiffRequest calls the generic Diff builder with application/json body
+func NewDiffRequest(server string, body DiffJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewDiffRequestWithBody(server, "application/json", bodyReader)
+}
+
+// NewDiffRequestWithBody generates requests for Diff with any type of body
+func NewDiffRequestWithBody(server string, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	queryUrl, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	basePath := fmt.Sprintf("/consent/diff")
+	if basePath[0] == '/' {
+		basePath = basePath[1:]
+	}
+
+	queryUrl, err = queryUrl.Parse(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", queryUrl.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+	return req, nil
+}
+
+// NewValidateRequest calls the generic Validate builder with application/json body
+func NewValidateRequest(server string, params *ValidateParams, body ValidateJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewValidateRequestWithBody(server, params, "application/json", bodyReader)
+}
+
+// NewValidateRequestWithBody generates requests for Validate with any type of body
+func NewValidateRequestWithBody(server string, params *ValidateParams, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	queryUrl, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	basePath := fmt.Sprintf("/consent/validate")
+	if basePath[0] == '/' {
+		basePath = basePath[1:]
+	}
+
+	queryUrl, err = queryUrl.Parse(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	queryValues := queryUrl.Query()
+
+	if params.FhirVersion != nil {
+
+		queryValues.Add("fhirVersion", *params.FhirVersion)
+
+	}
+
+	queryUrl.RawQuery = queryValues.Encode()
+
+	req, err := http.NewRequest("POST", queryUrl.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+	return req, nil
+}
+
+// NewValidateBatchRequest calls the generic ValidateBatch builder with application/json body
+func NewValidateBatchRequest(server string, params *ValidateBatchParams, body ValidateBatchJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewValidateBatchRequestWithBody(server, params, "application/json", bodyReader)
+}
+
+// NewValidateBatchRequestWithBody generates requests for ValidateBatch with any type of body
+func NewValidateBatchRequestWithBody(server string, params *ValidateBatchParams, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	queryUrl, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	basePath := fmt.Sprintf("/consent/validate/batch")
+	if basePath[0] == '/' {
+		basePath = basePath[1:]
+	}
+
+	queryUrl, err = queryUrl.Parse(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	queryValues := queryUrl.Query()
+
+	if params.FhirVersion != nil {
+
+		queryValues.Add("fhirVersion", *params.FhirVersion)
+
+	}
+
+	queryUrl.RawQuery = queryValues.Encode()
+
+	req, err := http.NewRequest("POST", queryUrl.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+	return req, nil
+}
+
+// NewValidateVersionRequest calls the generic ValidateVersion builder with application/json body
+func NewValidateVersionRequest(server string, body ValidateVersionJSONRequestBody) (*http.Request, error) {
+	var bodyReader io.Reader
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	bodyReader = bytes.NewReader(buf)
+	return NewValidateVersionRequestWithBody(server, "application/json", bodyReader)
+}
+
+// NewValidateVersionRequestWithBody generates requests for ValidateVersion with any type of body
+func NewValidateVersionRequestWithBody(server string, contentType string, body io.Reader) (*http.Request, error) {
+	var err error
+
+	queryUrl, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+
+	basePath := fmt.Sprintf("/consent/validate/version")
+	if basePath[0] == '/' {
+		basePath = basePath[1:]
+	}
+
+	queryUrl, err = queryUrl.Parse(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", queryUrl.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", contentType)
+	return req, nil
+}
+
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
+	// Standard FHIR $validate operation (http://hl7.org/fhir/resource-operation-validate.html) for Consent resources.
+	// (POST /Consent/$validate)
+	ValidateOperation(ctx echo.Context, params ValidateOperationParams) error
+	// Load the schemas, profiles and policy of the configuration again and replace the active rules.
+	// (POST /admin/reload)
+	Reload(ctx echo.Context) error
+	// Get the version and hash of the active schemas, profiles and policy.
+	// (GET /admin/rules)
+	GetRules(ctx echo.Context) error
+	// Build a Nuts consent record from simplified consent data. The result passes validation.
+	// (POST /consent/build)
+	Build(ctx echo.Context) error
+	// Get the registered consent classes with their descriptions and the FHIR resource types and profiles each class covers.
+	// (GET /consent/classes)
+	GetClasses(ctx echo.Context) error
+	// Decide if an actor is allowed to access a class of data of a subject at a given time according to the consent records.
+	// (POST /consent/decide)
+	Decide(ctx echo.Context) error
+	// Show what changed between two consent records.
+	// (POST /consent/diff)
+	Diff(ctx echo.Context) error
 	// Send a fhir consent record for validation. If valid the result will also include all accessible resources.
 	// (POST /consent/validate)
-	Validate(ctx echo.Context) error
+	Validate(ctx echo.Context, params ValidateParams) error
+	// Send many fhir consent records for validation in one call. Entries are validated concurrently.
+	// (POST /consent/validate/batch)
+	ValidateBatch(ctx echo.Context, params ValidateBatchParams) error
+	// Validate a new version of a consent record against the previous version.
+	// (POST /consent/validate/version)
+	ValidateVersion(ctx echo.Context) error
 }
 
 // ServerInterfaceWrapper converts echo contexts to parameters.
@@ -61,12 +1169,120 @@ type ServerInterfaceWrapper struct {
 	Handler ServerInterface
 }
 
+// ValidateOperation converts echo context to params.
+func (w *ServerInterfaceWrapper) ValidateOperation(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ValidateOperationParams
+	// ------------- Optional query parameter "fhirVersion" -------------
+	if paramValue := ctx.QueryParam("fhirVersion"); paramValue != "" {
+
+		params.FhirVersion = &paramValue
+
+	}
+
+	// Invoke the callback with all the unmarshalled arguments
+	err = w.Handler.ValidateOperation(ctx, params)
+	return err
+}
+
+// Reload converts echo context to params.
+func (w *ServerInterfaceWrapper) Reload(ctx echo.Context) error {
+	var err error
+
+	// Invoke the callback with all the unmarshalled arguments
+	err = w.Handler.Reload(ctx)
+	return err
+}
+
+// GetRules converts echo context to params.
+func (w *ServerInterfaceWrapper) GetRules(ctx echo.Context) error {
+	var err error
+
+	// Invoke the callback with all the unmarshalled arguments
+	err = w.Handler.GetRules(ctx)
+	return err
+}
+
+// Build converts echo context to params.
+func (w *ServerInterfaceWrapper) Build(ctx echo.Context) error {
+	var err error
+
+	// Invoke the callback with all the unmarshalled arguments
+	err = w.Handler.Build(ctx)
+	return err
+}
+
+// GetClasses converts echo context to params.
+func (w *ServerInterfaceWrapper) GetClasses(ctx echo.Context) error {
+	var err error
+
+	// Invoke the callback with all the unmarshalled arguments
+	err = w.Handler.GetClasses(ctx)
+	return err
+}
+
+// Decide converts echo context to params.
+func (w *ServerInterfaceWrapper) Decide(ctx echo.Context) error {
+	var err error
+
+	// Invoke the callback with all the unmarshalled arguments
+	err = w.Handler.Decide(ctx)
+	return err
+}
+
+// Diff converts echo context to params.
+func (w *ServerInterfaceWrapper) Diff(ctx echo.Context) error {
+	var err error
+
+	// Invoke the callback with all the unmarshalled arguments
+	err = w.Handler.Diff(ctx)
+	return err
+}
+
 // Validate converts echo context to params.
 func (w *ServerInterfaceWrapper) Validate(ctx echo.Context) error {
 	var err error
 
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ValidateParams
+	// ------------- Optional query parameter "fhirVersion" -------------
+	if paramValue := ctx.QueryParam("fhirVersion"); paramValue != "" {
+
+		params.FhirVersion = &paramValue
+
+	}
+
+	// Invoke the callback with all the unmarshalled arguments
+	err = w.Handler.Validate(ctx, params)
+	return err
+}
+
+// ValidateBatch converts echo context to params.
+func (w *ServerInterfaceWrapper) ValidateBatch(ctx echo.Context) error {
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ValidateBatchParams
+	// ------------- Optional query parameter "fhirVersion" -------------
+	if paramValue := ctx.QueryParam("fhirVersion"); paramValue != "" {
+
+		params.FhirVersion = &paramValue
+
+	}
+
+	// Invoke the callback with all the unmarshalled arguments
+	err = w.Handler.ValidateBatch(ctx, params)
+	return err
+}
+
+// ValidateVersion converts echo context to params.
+func (w *ServerInterfaceWrapper) ValidateVersion(ctx echo.Context) error {
+	var err error
+
 	// Invoke the callback with all the unmarshalled arguments
-	err = w.Handler.Validate(ctx)
+	err = w.Handler.ValidateVersion(ctx)
 	return err
 }
 
@@ -92,7 +1308,16 @@ func RegisterHandlers(router EchoRouter, si ServerInterface) {
 		Handler: si,
 	}
 
+	router.POST("/Consent/$validate", wrapper.ValidateOperation)
+	router.POST("/admin/reload", wrapper.Reload)
+	router.GET("/admin/rules", wrapper.GetRules)
+	router.POST("/consent/build", wrapper.Build)
+	router.GET("/consent/classes", wrapper.GetClasses)
+	router.POST("/consent/decide", wrapper.Decide)
+	router.POST("/consent/diff", wrapper.Diff)
 	router.POST("/consent/validate", wrapper.Validate)
+	router.POST("/consent/validate/batch", wrapper.ValidateBatch)
+	router.POST("/consent/validate/version", wrapper.ValidateVersion)
 
 }
 
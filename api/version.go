@@ -0,0 +1,66 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	"github.com/sirupsen/logrus"
+)
+
+// ValidateVersion handles the Post /consent/validate/version REST call. Both versions are validated before they are compared.
+// It returns a 200 code with the errors and changes of the update, a 400 code is returned when the request can not be parsed or one of the records is invalid.
+func (aw *ApiWrapper) ValidateVersion(ctx echo.Context) error {
+	buf, err := aw.readBody(ctx.Request())
+	if err != nil {
+		logrus.Error(err.Error())
+		return err
+	}
+
+	var request VersionValidationRequest
+	if err := json.Unmarshal(buf, &request); err != nil {
+		return ctx.String(http.StatusBadRequest, err.Error())
+	}
+
+	previous, err := aw.validConsent("previous", request.Previous)
+	if err != nil {
+		return recordError(ctx, err)
+	}
+	current, err := aw.validConsent("current", request.Current)
+	if err != nil {
+		return recordError(ctx, err)
+	}
+
+	errs, changes, err := pkg.ValidateVersion(previous, current)
+	if err != nil {
+		errs = []pkg.ValidationError{pkg.ErrorFrom(err)}
+	}
+
+	response := VersionValidationResponse{Outcome: "valid", Changes: changesFrom(changes)}
+	if len(errs) > 0 {
+		response.Outcome = "invalid"
+		response.ValidationErrors = validationErrorsFrom(errs)
+	}
+
+	return ctx.JSON(http.StatusOK, response)
+}
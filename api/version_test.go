@@ -0,0 +1,95 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nuts-foundation/nuts-go-core/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiWrapper_ValidateVersion(t *testing.T) {
+	client := validationBackend()
+	previous, _ := ioutil.ReadFile("../examples/observation_consent_unl.json")
+	current := strings.Replace(string(previous), `"versionId": "1"`, `"versionId": "2"`, 1)
+	body := func(previous string, current string) *http.Request {
+		return &http.Request{Body: ioutil.NopCloser(bytes.NewReader([]byte(fmt.Sprintf(`{"previous": %s, "current": %s}`, previous, current))))}
+	}
+
+	t.Run("valid update", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+		ended := strings.Replace(current, `"start": "2016-06-23T17:02:33+10:00"`, `"start": "2016-06-23T17:02:33+10:00", "end": "2017-01-01T00:00:00Z"`, 1)
+		end := "2017-01-01T00:00:00Z"
+
+		echo.EXPECT().Request().Return(body(string(previous), ended))
+		echo.EXPECT().JSON(http.StatusOK, VersionValidationResponse{
+			Outcome: "valid",
+			Changes: []Change{{Type: "period-end", New: &end}},
+		})
+
+		if err := client.ValidateVersion(echo); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("same version is invalid", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		var response VersionValidationResponse
+		echo.EXPECT().Request().Return(body(string(previous), string(previous)))
+		echo.EXPECT().JSON(http.StatusOK, gomock.Any()).DoAndReturn(func(code int, r interface{}) error {
+			response = r.(VersionValidationResponse)
+			return nil
+		})
+
+		if err := client.ValidateVersion(echo); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "invalid", response.Outcome)
+		assert.Equal(t, "version.versionId-invalid", (*response.ValidationErrors)[0].Code)
+	})
+
+	t.Run("invalid record", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		echo := mock.NewMockContext(ctrl)
+
+		echo.EXPECT().Request().Return(body(string(previous), "{}"))
+		echo.EXPECT().String(http.StatusBadRequest, gomock.Any()).DoAndReturn(func(code int, s string) error {
+			assert.True(t, strings.HasPrefix(s, "current: consent record is invalid"))
+			return nil
+		})
+
+		if err := client.ValidateVersion(echo); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"time"
+
+	"github.com/nuts-foundation/nuts-fhir-validation/api"
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	core "github.com/nuts-foundation/nuts-go-core"
+	"github.com/sirupsen/logrus"
+)
+
+// NewValidatorClient returns the default Validator client, either a local Validator or a HttpClient calling the configured node, based on the engine mode
+func NewValidatorClient() pkg.ValidatorClient {
+	validator := pkg.ValidatorInstance()
+
+	if core.NutsConfig().GetEngineMode(validator.Config.Mode) != core.ServerEngineMode {
+		timeout := validator.Config.ClientTimeout
+		if timeout <= 0 {
+			timeout = pkg.ConfigClientTimeoutDefault
+		}
+		return api.HttpClient{
+			ServerAddress: validator.Config.Address,
+			Timeout:       time.Duration(timeout) * time.Second,
+		}
+	}
+
+	if err := validator.Configure(); err != nil {
+		logrus.Panic(err)
+	}
+
+	return validator
+}
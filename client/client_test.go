@@ -0,0 +1,70 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nuts-foundation/nuts-fhir-validation/api"
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	core "github.com/nuts-foundation/nuts-go-core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewValidatorClient(t *testing.T) {
+	validator := pkg.ValidatorInstance()
+	config := validator.Config
+	defer func() {
+		validator.Config = config
+	}()
+
+	t.Run("client mode returns a HttpClient for the configured address", func(t *testing.T) {
+		validator.Config.Mode = core.ClientEngineMode
+		validator.Config.Address = "localhost:1323"
+		validator.Config.ClientTimeout = 5
+
+		client := NewValidatorClient()
+
+		if assert.IsType(t, api.HttpClient{}, client) {
+			assert.Equal(t, "localhost:1323", client.(api.HttpClient).ServerAddress)
+			assert.Equal(t, 5*time.Second, client.(api.HttpClient).Timeout)
+		}
+	})
+
+	t.Run("client mode without timeout uses the default timeout", func(t *testing.T) {
+		validator.Config.Mode = core.ClientEngineMode
+		validator.Config.ClientTimeout = 0
+
+		client := NewValidatorClient()
+
+		if assert.IsType(t, api.HttpClient{}, client) {
+			assert.Equal(t, time.Duration(pkg.ConfigClientTimeoutDefault)*time.Second, client.(api.HttpClient).Timeout)
+		}
+	})
+
+	t.Run("server mode returns the local validator", func(t *testing.T) {
+		validator.Config.Mode = core.ServerEngineMode
+
+		client := NewValidatorClient()
+
+		assert.Same(t, validator, client)
+	})
+}
@@ -20,13 +20,15 @@
 package engine
 
 import (
+	"io/ioutil"
+
+	"github.com/labstack/echo/v4"
 	"github.com/nuts-foundation/nuts-fhir-validation/api"
+	"github.com/nuts-foundation/nuts-fhir-validation/client"
 	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
 	engine "github.com/nuts-foundation/nuts-go-core"
-	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
-	"github.com/thedevsaddam/gojsonq/v2"
 )
 
 // NewValidationEngine creates a new Engine configuration
@@ -41,6 +43,10 @@ func NewValidationEngine() *engine.Engine {
 		FlagSet:   flagSet(),
 		Name:      "Validation",
 		Routes: func(router engine.EchoRouter) {
+			// the read timeout can only end a request body that stops sending when the connection is known
+			if e, ok := router.(*echo.Echo); ok {
+				api.ConfigureServer(e.Server)
+			}
 			api.RegisterHandlers(router, &api.ApiWrapper{Vb: vb})
 		},
 	}
@@ -52,13 +58,34 @@ func cmd(vb *pkg.Validator) *cobra.Command {
 		Short: "validation commands",
 	}
 
+	cmd.PersistentFlags().String(flagOutput, OutputText, "output format: text, json or yaml")
+
 	cmd.AddCommand(&cobra.Command{
-		Use:   "consent [path_to/consent.json]",
-		Short: "validate the consent record at the given location",
+		Use:   "consent [path_to/consent.json|directory|pattern|-]...",
+		Short: "validate the consent records at the given locations",
+		Long: `validate the consent records at the given locations, directories are searched recursively for .json files, patterns are expanded and - reads from stdin.
+A single record is reported in full, for multiple records a summary is given.
+Exits with 0 when all records are valid, 1 when a record is invalid and 2 when a record could not be validated.`,
 
-		Args: cobra.ExactArgs(1),
+		Args: cobra.MinimumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			vb.ValidateAgainstSchemaConsentAt(args[0])
+			run(cmd, func(p printer) (int, error) {
+				sources, err := expandSources(args)
+				if err != nil {
+					return ExitError, err
+				}
+
+				s := validateSources(client.NewValidatorClient(), sources, cmd.InOrStdin())
+				if len(args) == 1 && s.Total == 1 && s.Errors == 0 {
+					f := s.Files[0]
+					if err := p.printReport(&pkg.ValidationReport{Outcome: f.Outcome, FHIRVersion: f.Version, Errors: f.Errors, Consent: f.Consent}); err != nil {
+						return ExitError, err
+					}
+				} else if err := p.printSummary(s); err != nil {
+					return ExitError, err
+				}
+				return s.exitCode(), nil
+			})
 		},
 	})
 
@@ -68,8 +95,17 @@ func cmd(vb *pkg.Validator) *cobra.Command {
 
 		Args: cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			jsonqString := jsonqFromFile(args[0])
-			logrus.Error(pkg.SubjectFrom(jsonqString))
+			run(cmd, func(p printer) (int, error) {
+				consent, err := consentFromFile(args[0])
+				if err != nil {
+					return ExitError, err
+				}
+				value, err := consent.Subject()
+				if err != nil {
+					return ExitError, err
+				}
+				return ExitValid, p.print(value, value)
+			})
 		},
 	})
 
@@ -79,8 +115,17 @@ func cmd(vb *pkg.Validator) *cobra.Command {
 
 		Args: cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			jsonqString := jsonqFromFile(args[0])
-			logrus.Error(pkg.CustodianFrom(jsonqString))
+			run(cmd, func(p printer) (int, error) {
+				consent, err := consentFromFile(args[0])
+				if err != nil {
+					return ExitError, err
+				}
+				value, err := consent.Custodian()
+				if err != nil {
+					return ExitError, err
+				}
+				return ExitValid, p.print(value, value)
+			})
 		},
 	})
 
@@ -90,8 +135,21 @@ func cmd(vb *pkg.Validator) *cobra.Command {
 
 		Args: cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			jsonqString := jsonqFromFile(args[0])
-			logrus.Error(pkg.ActorsFrom(jsonqString))
+			run(cmd, func(p printer) (int, error) {
+				consent, err := consentFromFile(args[0])
+				if err != nil {
+					return ExitError, err
+				}
+				value, err := consent.Actors()
+				if err != nil {
+					return ExitError, err
+				}
+				lines := make([]string, len(value))
+				for i, a := range value {
+					lines[i] = string(a)
+				}
+				return ExitValid, p.print(value, lines...)
+			})
 		},
 	})
 
@@ -101,8 +159,46 @@ func cmd(vb *pkg.Validator) *cobra.Command {
 
 		Args: cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			jsonqString := jsonqFromFile(args[0])
-			logrus.Error(pkg.ResourcesFrom(jsonqString))
+			run(cmd, func(p printer) (int, error) {
+				consent, err := consentFromFile(args[0])
+				if err != nil {
+					return ExitError, err
+				}
+				value, err := consent.DataClasses()
+				if err != nil {
+					return ExitError, err
+				}
+				return ExitValid, p.print(value, value...)
+			})
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "diff [path_to/old.json] [path_to/new.json]",
+		Short: "show the changes between two consent records",
+
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			run(cmd, func(p printer) (int, error) {
+				old, err := consentFromFile(args[0])
+				if err != nil {
+					return ExitError, err
+				}
+				new, err := consentFromFile(args[1])
+				if err != nil {
+					return ExitError, err
+				}
+
+				changes, err := pkg.Diff(old, new)
+				if err != nil {
+					return ExitError, err
+				}
+				lines := make([]string, len(changes))
+				for i, c := range changes {
+					lines[i] = c.String()
+				}
+				return ExitValid, p.print(append([]pkg.Change{}, changes...), lines...)
+			})
 		},
 	})
 
@@ -112,11 +208,30 @@ func cmd(vb *pkg.Validator) *cobra.Command {
 func flagSet() *pflag.FlagSet {
 	flags := pflag.NewFlagSet("validate", pflag.ContinueOnError)
 
+	flags.String(pkg.ConfigMode, "", "server or client, when client it uses the HttpClient to validate at the node given by address")
+	flags.String(pkg.ConfigAddress, pkg.ConfigAddressDefault, "address of the Nuts node used in client mode")
+	flags.Int(pkg.ConfigClientTimeout, pkg.ConfigClientTimeoutDefault, "timeout in seconds for calls to the Nuts node in client mode")
+	flags.Int(pkg.ConfigMaxSize, pkg.ConfigMaxSizeDefault, "maximum size of a consent record or request body in bytes, 0 is unlimited")
+	flags.Int(pkg.ConfigReadTimeout, pkg.ConfigReadTimeoutDefault, "timeout in seconds for reading a consent record or request body, 0 is unlimited")
+	flags.String(pkg.ConfigFHIRVersion, pkg.ConfigFHIRVersionDefault, "FHIR version of consent records that do not specify one: 3.0 (STU3), 4.0 (R4) or 5.0 (R5)")
 	flags.String(pkg.ConfigSchemaPath, pkg.ConfigSchemaPathDefault, "location of json schema, default nested Asset")
+	flags.Bool(pkg.ConfigFullSchema, pkg.ConfigFullSchemaDefault, "validate against the full FHIR schema instead of the reduced Consent schema")
+	flags.String(pkg.ConfigProfiles, "", "comma separated list of StructureDefinition, ValueSet or Bundle files and directories, selected by meta.profile of a consent record")
+	flags.String(pkg.ConfigClassRegistry, pkg.ConfigClassRegistryDefault, "location of the consent class registry, default the bundled registry")
+	flags.String(pkg.ConfigPolicyCustodians, "", "comma separated list of custodian identifiers this node accepts consent records for, default all")
+	flags.String(pkg.ConfigPolicyClasses, "", "comma separated list of consent classes this node accepts, default all")
+	flags.String(pkg.ConfigPolicyContentTypes, "", "comma separated list of sourceAttachment content types this node accepts as proof, default all")
+	flags.Int(pkg.ConfigPolicyMaxPeriod, 0, "maximum length of a provision period in days, default unlimited")
+	flags.String(pkg.ConfigPolicyFile, "", "yaml file with the node policy, replaces the other policy settings and is read again on reload")
+	flags.Bool(pkg.ConfigReload, pkg.ConfigReloadDefault, "allow POST /admin/reload from a loopback address of the node, keep it off when a reverse proxy on the node forwards requests")
 
 	return flags
 }
 
-func jsonqFromFile(source string) *gojsonq.JSONQ {
-	return gojsonq.New().File(source)
+func consentFromFile(source string) (*pkg.Consent, error) {
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	return pkg.ParseConsent(data)
 }
@@ -0,0 +1,203 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package engine
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+)
+
+// stdinSource is the argument used to read a consent record from stdin
+const stdinSource = "-"
+
+// outcomeError is used in the summary for files that could not be validated
+const outcomeError = "error"
+
+// fileResult is the outcome of the validation of a single file
+type fileResult struct {
+	Source  string                 `json:"source"`
+	Outcome string                 `json:"outcome"`
+	Version string                 `json:"fhirVersion,omitempty"`
+	Errors  []pkg.ValidationError  `json:"validationErrors,omitempty"`
+	Consent *pkg.SimplifiedConsent `json:"consent,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+// summary holds the results of all validated files with totals per outcome
+type summary struct {
+	Files   []fileResult `json:"files"`
+	Total   int          `json:"total"`
+	Valid   int          `json:"valid"`
+	Invalid int          `json:"invalid"`
+	Errors  int          `json:"errors"`
+}
+
+// expandSources converts the arguments to the list of consent records to validate.
+// Directories are searched recursively for .json files, glob patterns are expanded and - is stdin.
+// An error is returned when a pattern or directory results in no consent records, so an empty run is never reported as valid.
+func expandSources(args []string) ([]string, error) {
+	var sources []string
+	seen := map[string]bool{}
+	add := func(source string) {
+		if !seen[source] {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+
+	for _, arg := range args {
+		if arg == stdinSource {
+			add(arg)
+			continue
+		}
+
+		paths := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %s", arg)
+			}
+			paths = matches
+		}
+
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(path)
+				continue
+			}
+			found := 0
+			err = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if !fi.IsDir() && strings.EqualFold(filepath.Ext(p), ".json") {
+					found++
+					add(p)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			if found == 0 && len(paths) == 1 {
+				return nil, fmt.Errorf("no .json files in %s", path)
+			}
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no consent records in %s", strings.Join(args, ", "))
+	}
+	return sources, nil
+}
+
+// validateSources validates all sources concurrently, the results have the same order as the sources
+func validateSources(client pkg.ValidatorClient, sources []string, stdin io.Reader) summary {
+	results := make([]fileResult, len(sources))
+
+	indices := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < runtime.NumCPU() && w < len(sources); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				results[i] = validateSource(client, sources[i], stdin)
+			}
+		}()
+	}
+
+	for i := range sources {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	s := summary{Files: results, Total: len(results)}
+	for _, r := range results {
+		switch r.Outcome {
+		case pkg.OutcomeValid:
+			s.Valid++
+		case pkg.OutcomeInvalid:
+			s.Invalid++
+		default:
+			s.Errors++
+		}
+	}
+	return s
+}
+
+func validateSource(client pkg.ValidatorClient, source string, stdin io.Reader) fileResult {
+	var report *pkg.ValidationReport
+	var err error
+	if source == stdinSource {
+		report, err = client.ValidateReader(stdin)
+	} else {
+		report, err = client.ValidateFile(source)
+	}
+	if err != nil {
+		return fileResult{Source: source, Outcome: outcomeError, Error: err.Error()}
+	}
+
+	return fileResult{Source: source, Outcome: report.Outcome, Version: report.FHIRVersion, Errors: report.Errors, Consent: report.Consent}
+}
+
+// exitCode returns ExitError when a file could not be validated, ExitInvalid when a file is invalid and ExitValid otherwise
+func (s summary) exitCode() int {
+	switch {
+	case s.Errors > 0:
+		return ExitError
+	case s.Invalid > 0:
+		return ExitInvalid
+	}
+	return ExitValid
+}
+
+// printSummary writes the outcome per file followed by the totals
+func (p printer) printSummary(s summary) error {
+	var lines []string
+	for _, r := range s.Files {
+		if r.Outcome == outcomeError {
+			lines = append(lines, fmt.Sprintf("%-8s %s: %s", r.Outcome, r.Source, r.Error))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%-8s %s", r.Outcome, r.Source))
+		for _, e := range r.Errors {
+			lines = append(lines, fmt.Sprintf("  - %s (%s)", e.Message, e.Code))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("%d files: %d valid, %d invalid, %d errors", s.Total, s.Valid, s.Invalid, s.Errors))
+
+	return p.print(s, lines...)
+}
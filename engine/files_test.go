@@ -0,0 +1,151 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/nuts-foundation/nuts-fhir-validation/mock"
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "consents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, f := range []string{"a.json", "b.txt", "nested/c.json", "nested/d.JSON"} {
+		path := filepath.Join(dir, f)
+		os.MkdirAll(filepath.Dir(path), 0700)
+		ioutil.WriteFile(path, []byte("{}"), 0600)
+	}
+
+	t.Run("directories are searched recursively for json files", func(t *testing.T) {
+		sources, err := expandSources([]string{dir})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(dir, "a.json"),
+			filepath.Join(dir, "nested/c.json"),
+			filepath.Join(dir, "nested/d.JSON"),
+		}, sources)
+	})
+
+	t.Run("patterns are expanded and duplicates removed", func(t *testing.T) {
+		sources, err := expandSources([]string{filepath.Join(dir, "*"), filepath.Join(dir, "a.json"), "-"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(dir, "a.json"),
+			filepath.Join(dir, "b.txt"),
+			filepath.Join(dir, "nested/c.json"),
+			filepath.Join(dir, "nested/d.JSON"),
+			"-",
+		}, sources)
+	})
+
+	t.Run("pattern without matches", func(t *testing.T) {
+		_, err := expandSources([]string{filepath.Join(dir, "*.xml")})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("directory without json files", func(t *testing.T) {
+		empty := filepath.Join(dir, "empty")
+		os.MkdirAll(filepath.Join(empty, "nested"), 0700)
+		ioutil.WriteFile(filepath.Join(empty, "consent.txt"), []byte("{}"), 0600)
+		defer os.RemoveAll(empty)
+
+		_, err := expandSources([]string{empty})
+
+		assert.EqualError(t, err, "no .json files in "+empty)
+	})
+
+	t.Run("pattern matching only directories without json files", func(t *testing.T) {
+		empty := filepath.Join(dir, "empty")
+		os.MkdirAll(filepath.Join(empty, "a"), 0700)
+		os.MkdirAll(filepath.Join(empty, "b"), 0700)
+		defer os.RemoveAll(empty)
+
+		_, err := expandSources([]string{filepath.Join(empty, "*")})
+
+		assert.EqualError(t, err, "no consent records in "+filepath.Join(empty, "*"))
+	})
+
+	t.Run("unknown file", func(t *testing.T) {
+		_, err := expandSources([]string{filepath.Join(dir, "unknown.json")})
+
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestValidateSources(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock.NewMockValidatorClient(ctrl)
+	stdin := strings.NewReader("{}")
+
+	client.EXPECT().ValidateFile("valid.json").Return(&pkg.ValidationReport{Outcome: pkg.OutcomeValid}, nil)
+	client.EXPECT().ValidateFile("invalid.json").Return(&pkg.ValidationReport{
+		Outcome: pkg.OutcomeInvalid,
+		Errors:  []pkg.ValidationError{{Code: "required", Message: "(root): scope is required"}},
+	}, nil)
+	client.EXPECT().ValidateFile("unknown.json").Return(nil, errors.New("open unknown.json: no such file or directory"))
+	client.EXPECT().ValidateReader(stdin).Return(&pkg.ValidationReport{Outcome: pkg.OutcomeValid}, nil)
+
+	s := validateSources(client, []string{"valid.json", "invalid.json", "unknown.json", "-"}, stdin)
+
+	assert.Equal(t, 4, s.Total)
+	assert.Equal(t, 2, s.Valid)
+	assert.Equal(t, 1, s.Invalid)
+	assert.Equal(t, 1, s.Errors)
+	assert.Equal(t, "-", s.Files[3].Source)
+	assert.Equal(t, ExitError, s.exitCode())
+
+	t.Run("text summary", func(t *testing.T) {
+		out := new(bytes.Buffer)
+
+		err := printer{format: OutputText, out: out}.printSummary(s)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `valid    valid.json
+invalid  invalid.json
+  - (root): scope is required (required)
+error    unknown.json: open unknown.json: no such file or directory
+valid    -
+4 files: 2 valid, 1 invalid, 1 errors
+`, out.String())
+	})
+}
+
+func TestSummary_exitCode(t *testing.T) {
+	assert.Equal(t, ExitValid, summary{Total: 1, Valid: 1}.exitCode())
+	assert.Equal(t, ExitInvalid, summary{Total: 2, Valid: 1, Invalid: 1}.exitCode())
+	assert.Equal(t, ExitError, summary{Total: 2, Invalid: 1, Errors: 1}.exitCode())
+}
@@ -0,0 +1,158 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// Exit codes of the validation commands
+const (
+	// ExitValid is used when the record is valid or the command succeeded
+	ExitValid = 0
+	// ExitInvalid is used when the record is invalid or a value can not be extracted from it
+	ExitInvalid = 1
+	// ExitError is used when the command could not be executed, eg: the file does not exist
+	ExitError = 2
+)
+
+// Output formats of the validation commands
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+)
+
+// --output flag of the validation commands
+const flagOutput = "output"
+
+// exit is replaced in tests
+var exit = os.Exit
+
+// printer writes command results to stdout in the requested format
+type printer struct {
+	format string
+	out    io.Writer
+}
+
+// run executes the command and exits with the returned code, errors are written to stderr
+func run(cmd *cobra.Command, f func(p printer) (int, error)) {
+	if code := execute(cmd, f); code != ExitValid {
+		exit(code)
+	}
+}
+
+func execute(cmd *cobra.Command, f func(p printer) (int, error)) int {
+	format, _ := cmd.Flags().GetString(flagOutput)
+	p := printer{format: format, out: cmd.OutOrStdout()}
+
+	var code int
+	err := p.check()
+	if err == nil {
+		code, err = f(p)
+	}
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "error: %v\n", err)
+		return exitCode(err)
+	}
+	return code
+}
+
+// exitCode returns ExitInvalid when a value could not be extracted from the record, ExitError otherwise
+func exitCode(err error) int {
+	var ee pkg.ExtractionError
+	if errors.As(err, &ee) {
+		return ExitInvalid
+	}
+	return ExitError
+}
+
+func (p printer) check() error {
+	switch p.format {
+	case OutputText, OutputJSON, OutputYAML:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %s, use %s, %s or %s", p.format, OutputText, OutputJSON, OutputYAML)
+}
+
+// print writes the value as json or yaml, the lines are used for text output
+func (p printer) print(value interface{}, lines ...string) error {
+	switch p.format {
+	case OutputJSON:
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(p.out, string(data))
+		return err
+	case OutputYAML:
+		// convert through json to use the json field names
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		if data, err = yaml.Marshal(v); err != nil {
+			return err
+		}
+		_, err = p.out.Write(data)
+		return err
+	}
+
+	for _, l := range lines {
+		if _, err := fmt.Fprintln(p.out, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printReport writes the validation report, the text output starts with the outcome followed by the errors or the simplified consent
+func (p printer) printReport(report *pkg.ValidationReport) error {
+	lines := []string{report.Outcome}
+	for _, e := range report.Errors {
+		lines = append(lines, fmt.Sprintf("- %s (%s)", e.Message, e.Code))
+	}
+	if c := report.Consent; c != nil {
+		actors := make([]string, len(c.Actors))
+		for i, a := range c.Actors {
+			actors[i] = string(a)
+		}
+		lines = append(lines,
+			fmt.Sprintf("subject:   %s", c.Subject),
+			fmt.Sprintf("custodian: %s", c.Custodian),
+			fmt.Sprintf("actors:    %s", strings.Join(actors, ", ")),
+			fmt.Sprintf("resources: %s", strings.Join(c.Resources, ", ")),
+		)
+	}
+	return p.print(report, lines...)
+}
@@ -0,0 +1,147 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/nuts-foundation/nuts-fhir-validation/pkg"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func testCommand(format string) (*cobra.Command, *bytes.Buffer, *bytes.Buffer) {
+	cmd := &cobra.Command{}
+	cmd.Flags().String(flagOutput, OutputText, "")
+	cmd.Flags().Set(flagOutput, format)
+	out, errOut := new(bytes.Buffer), new(bytes.Buffer)
+	cmd.SetOut(out)
+	cmd.SetErr(errOut)
+	return cmd, out, errOut
+}
+
+func TestExecute(t *testing.T) {
+	t.Run("returns the code of the command", func(t *testing.T) {
+		cmd, _, _ := testCommand(OutputText)
+
+		code := execute(cmd, func(p printer) (int, error) {
+			return ExitInvalid, nil
+		})
+
+		assert.Equal(t, ExitInvalid, code)
+	})
+
+	t.Run("errors are written to stderr", func(t *testing.T) {
+		cmd, out, errOut := testCommand(OutputText)
+
+		code := execute(cmd, func(p printer) (int, error) {
+			return ExitError, errors.New("b00m!")
+		})
+
+		assert.Equal(t, ExitError, code)
+		assert.Empty(t, out.String())
+		assert.Equal(t, "error: b00m!\n", errOut.String())
+	})
+
+	t.Run("extraction errors make the record invalid", func(t *testing.T) {
+		cmd, _, _ := testCommand(OutputText)
+
+		code := execute(cmd, func(p printer) (int, error) {
+			consent, _ := pkg.ParseConsent([]byte(`{"resourceType": "Consent"}`))
+			_, err := consent.Subject()
+			return ExitError, err
+		})
+
+		assert.Equal(t, ExitInvalid, code)
+	})
+
+	t.Run("unknown output format", func(t *testing.T) {
+		cmd, _, errOut := testCommand("xml")
+
+		code := execute(cmd, func(p printer) (int, error) {
+			t.Fatal("command should not be executed")
+			return ExitValid, nil
+		})
+
+		assert.Equal(t, ExitError, code)
+		assert.Contains(t, errOut.String(), "unknown output format xml")
+	})
+}
+
+func TestPrinter_printReport(t *testing.T) {
+	report := &pkg.ValidationReport{
+		Outcome: pkg.OutcomeValid,
+		Consent: &pkg.SimplifiedConsent{
+			Subject:   "urn:oid:2.16.840.1.113883.2.4.6.3:999999990",
+			Custodian: "urn:oid:2.16.840.1.113883.2.4.6.1:00000000",
+			Actors:    []pkg.Identifier{"urn:oid:2.16.840.1.113883.2.4.6.1:00000007"},
+			Resources: []string{"urn:oid:1.3.6.1.4.1.54851.1:MEDICAL"},
+		},
+	}
+
+	t.Run("text", func(t *testing.T) {
+		out := new(bytes.Buffer)
+
+		err := printer{format: OutputText, out: out}.printReport(report)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `valid
+subject:   urn:oid:2.16.840.1.113883.2.4.6.3:999999990
+custodian: urn:oid:2.16.840.1.113883.2.4.6.1:00000000
+actors:    urn:oid:2.16.840.1.113883.2.4.6.1:00000007
+resources: urn:oid:1.3.6.1.4.1.54851.1:MEDICAL
+`, out.String())
+	})
+
+	t.Run("text with errors", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		invalid := &pkg.ValidationReport{
+			Outcome: pkg.OutcomeInvalid,
+			Errors:  []pkg.ValidationError{{Code: "required", Message: "(root): scope is required"}},
+		}
+
+		err := printer{format: OutputText, out: out}.printReport(invalid)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "invalid\n- (root): scope is required (required)\n", out.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out := new(bytes.Buffer)
+
+		err := printer{format: OutputJSON, out: out}.printReport(report)
+
+		assert.NoError(t, err)
+		assert.Contains(t, out.String(), `"outcome": "valid"`)
+		assert.Contains(t, out.String(), `"subject": "urn:oid:2.16.840.1.113883.2.4.6.3:999999990"`)
+	})
+
+	t.Run("yaml uses the json field names", func(t *testing.T) {
+		out := new(bytes.Buffer)
+
+		err := printer{format: OutputYAML, out: out}.printReport(report)
+
+		assert.NoError(t, err)
+		assert.Contains(t, out.String(), "outcome: valid\n")
+		assert.Contains(t, out.String(), "  resources:\n  - urn:oid:1.3.6.1.4.1.54851.1:MEDICAL\n")
+	})
+}
@@ -1,6 +1,6 @@
 module github.com/nuts-foundation/nuts-fhir-validation
 
-go 1.13
+go 1.16
 
 require (
 	github.com/golang/mock v1.4.4
@@ -14,4 +14,5 @@ require (
 	github.com/thedevsaddam/gojsonq/v2 v2.5.2
 	github.com/xeipuuv/gojsonpointer v0.0.0-20190905194746-02993c407bfb // indirect
 	github.com/xeipuuv/gojsonschema v1.2.0
+	gopkg.in/yaml.v2 v2.2.8
 )
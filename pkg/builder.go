@@ -0,0 +1,224 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// codes used by the builder for the fixed parts of a Nuts consent record
+const (
+	consentScopeSystem  = "http://terminology.hl7.org/CodeSystem/consentscope"
+	consentScope        = "patient-privacy"
+	loincSystem         = "http://loinc.org"
+	privacyConsentLoinc = "64292-6"
+)
+
+// ConsentSpec holds the simplified data a Nuts consent record is built from.
+// Identifiers are given as urn:oid:system:value, classes as urn:oid:system:code or system#code, the same format the extractors return.
+type ConsentSpec struct {
+	Subject   string
+	Custodian string
+	// Performer is the organization that recorded the consent, the custodian when empty
+	Performer string
+	Actors    []Identifier
+	Classes   []string
+	// Actions from http://terminology.hl7.org/CodeSystem/consentaction, access when empty
+	Actions []string
+	Start   time.Time
+	End     *time.Time
+	Proof   Attachment
+	// Version is the meta.versionId, 1 when zero
+	Version int
+	// LastUpdated is the meta.lastUpdated, now when zero
+	LastUpdated time.Time
+}
+
+// BuildConsent builds a Nuts consent record from the spec and checks the result with all validation stages of ValidateFHIRVersion for R4:
+// schema, Nuts consent profile, class registry, node policy and extraction of the simplified consent.
+// An error is returned when the spec is incomplete or the result does not pass validation, it holds the messages of all validation errors.
+func (ve *Validator) BuildConsent(spec ConsentSpec) ([]byte, error) {
+	consent, err := Build(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(consent)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := ve.ValidateFHIRVersion(data, FHIRVersionR4)
+	if err != nil {
+		return nil, err
+	}
+	if !report.Valid() {
+		var messages []string
+		for _, e := range report.Errors {
+			messages = append(messages, e.Message)
+		}
+		return nil, fmt.Errorf("built consent record is invalid: %s", strings.Join(messages, "; "))
+	}
+
+	return data, nil
+}
+
+// Build creates a Consent with an OPTIN policyRule and a single permit provision for the actors and classes of the spec
+func Build(spec ConsentSpec) (*Consent, error) {
+	subject, err := referenceTo("subject", "Patient", spec.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if subject.Identifier.System != NutsPatientSystem {
+		return nil, fmt.Errorf("subject: system must be %s", NutsPatientSystem)
+	}
+	custodian, err := referenceTo("custodian", "", spec.Custodian)
+	if err != nil {
+		return nil, err
+	}
+	performerID := spec.Performer
+	if performerID == "" {
+		performerID = spec.Custodian
+	}
+	performer, err := referenceTo("performer", "Organization", performerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(spec.Actors) == 0 {
+		return nil, errors.New("actors: at least one actor is required")
+	}
+	var actors []ProvisionActor
+	for i, a := range spec.Actors {
+		reference, err := referenceTo(fmt.Sprintf("actors.%d", i), "", string(a))
+		if err != nil {
+			return nil, err
+		}
+		actors = append(actors, ProvisionActor{
+			Role:      CodeableConcept{Coding: []Coding{{System: ParticipationTypeSystem, Code: actorRole}}},
+			Reference: reference,
+		})
+	}
+
+	if len(spec.Classes) == 0 {
+		return nil, errors.New("classes: at least one class is required")
+	}
+	var classes []Coding
+	for i, c := range spec.Classes {
+		coding, err := codingFrom(c)
+		if err != nil {
+			return nil, fmt.Errorf("classes.%d: %w", i, err)
+		}
+		classes = append(classes, coding)
+	}
+
+	actions := spec.Actions
+	if len(actions) == 0 {
+		actions = []string{defaultAction}
+	}
+	var actionConcepts []CodeableConcept
+	for i, a := range actions {
+		if !contains(allowedActions, a) {
+			return nil, fmt.Errorf("actions.%d: action must be one of %s", i, strings.Join(allowedActions, ", "))
+		}
+		actionConcepts = append(actionConcepts, CodeableConcept{Coding: []Coding{{System: ConsentActionSystem, Code: a}}})
+	}
+
+	if spec.Start.IsZero() {
+		return nil, errors.New("start: start of the period is required")
+	}
+	period := &Period{Start: spec.Start.Format(time.RFC3339)}
+	if spec.End != nil {
+		if spec.End.Before(spec.Start) {
+			return nil, errors.New("end: end of the period can not be before the start")
+		}
+		period.End = spec.End.Format(time.RFC3339)
+	}
+
+	if spec.Proof.ContentType == "" || (spec.Proof.Data == "" && spec.Proof.URL == "") {
+		return nil, errors.New("proof: contentType and data or url are required")
+	}
+	proof := spec.Proof
+
+	version := spec.Version
+	if version == 0 {
+		version = 1
+	}
+	if version < 0 {
+		return nil, errors.New("version: version must be 1 or higher")
+	}
+	lastUpdated := spec.LastUpdated
+	if lastUpdated.IsZero() {
+		lastUpdated = time.Now()
+	}
+
+	return &Consent{
+		ResourceType:     consentResourceType,
+		Meta:             &Meta{VersionID: strconv.Itoa(version), LastUpdated: lastUpdated.Format(time.RFC3339)},
+		Scope:            &CodeableConcept{Coding: []Coding{{System: consentScopeSystem, Code: consentScope}}},
+		Category:         []CodeableConcept{{Coding: []Coding{{System: loincSystem, Code: privacyConsentLoinc}}}},
+		Patient:          &Reference{Identifier: subject.Identifier},
+		DateTime:         lastUpdated.Format(time.RFC3339),
+		Performer:        []Reference{performer},
+		Organization:     []Reference{custodian},
+		SourceAttachment: &proof,
+		Verification:     []Verification{{Verified: true, VerifiedWith: &subject}},
+		PolicyRule:       &CodeableConcept{Coding: []Coding{{System: PolicyRuleSystem, Code: policyOptIn}}},
+		Provision: &Provision{
+			Actor:  actors,
+			Period: period,
+			Provision: []Provision{{
+				Type:   ProvisionPermit,
+				Action: actionConcepts,
+				Class:  classes,
+			}},
+		},
+	}, nil
+}
+
+// referenceTo creates a logical reference from an urn:oid:system:value identifier
+func referenceTo(field string, resourceType string, identifier string) (Reference, error) {
+	i := strings.LastIndex(identifier, ":")
+	if !strings.HasPrefix(identifier, nutsOIDPrefix) || i <= len(nutsOIDPrefix) || i == len(identifier)-1 {
+		return Reference{}, fmt.Errorf("%s: identifier must have the form urn:oid:system:value, got [%s]", field, identifier)
+	}
+	return Reference{
+		Type:       resourceType,
+		Identifier: &FHIRIdentifier{System: identifier[:i], Value: identifier[i+1:]},
+	}, nil
+}
+
+// codingFrom is the reverse of Coding.String: urn:oid systems use : as divider, others #
+func codingFrom(class string) (Coding, error) {
+	divider := "#"
+	if strings.HasPrefix(class, nutsOIDPrefix) {
+		divider = ":"
+	}
+	i := strings.LastIndex(class, divider)
+	if i <= 0 || i == len(class)-1 || (divider == ":" && i <= len(nutsOIDPrefix)) {
+		return Coding{}, fmt.Errorf("class must have the form urn:oid:system:code or system#code, got [%s]", class)
+	}
+	return Coding{System: class[:i], Code: class[i+1:]}, nil
+}
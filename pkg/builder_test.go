@@ -0,0 +1,139 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidator_BuildConsent(t *testing.T) {
+	client := validationBackend()
+	start := time.Date(2016, 6, 23, 17, 2, 33, 0, time.UTC)
+	spec := func() ConsentSpec {
+		return ConsentSpec{
+			Subject:   "urn:oid:2.16.840.1.113883.2.4.6.3:999999990",
+			Custodian: "urn:oid:2.16.840.1.113883.2.4.6.1:00000000",
+			Actors:    []Identifier{"urn:oid:2.16.840.1.113883.2.4.6.1:00000007"},
+			Classes:   []string{"http://hl7.org/fhir/resource-types#Observation", "urn:oid:1.3.6.1.4.1.54851.1:MEDICAL"},
+			Start:     start,
+			Proof:     Attachment{ContentType: "application/pdf", Data: "dhklauHAELrlg78OLg=="},
+		}
+	}
+
+	t.Run("built consent passes validation and extraction", func(t *testing.T) {
+		data, err := client.BuildConsent(spec())
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		valid, errs, err := client.ValidateAgainstSchema(data)
+		assert.NoError(t, err)
+		assert.True(t, valid)
+		assert.Empty(t, errs)
+
+		consent, _ := ParseConsent(data)
+		subject, _ := consent.Subject()
+		assert.Equal(t, "urn:oid:2.16.840.1.113883.2.4.6.3:999999990", subject)
+		custodian, _ := consent.Custodian()
+		assert.Equal(t, "urn:oid:2.16.840.1.113883.2.4.6.1:00000000", custodian)
+		actors, _ := consent.Actors()
+		assert.Equal(t, []Identifier{"urn:oid:2.16.840.1.113883.2.4.6.1:00000007"}, actors)
+		classes, _ := consent.DataClasses()
+		assert.Equal(t, spec().Classes, classes)
+		period, _ := consent.Period()
+		assert.True(t, start.Equal(*period[0]))
+		assert.Nil(t, period[1])
+		version, _ := consent.Version()
+		assert.Equal(t, "1", version)
+	})
+
+	t.Run("subject must be a BSN", func(t *testing.T) {
+		s := spec()
+		s.Subject = "urn:oid:2.16.840.1.113883.2.4.6.1:999999990"
+
+		_, err := client.BuildConsent(s)
+
+		assert.EqualError(t, err, "subject: system must be urn:oid:2.16.840.1.113883.2.4.6.3")
+	})
+
+	t.Run("identifiers must be urn:oid", func(t *testing.T) {
+		s := spec()
+		s.Actors = []Identifier{"Organization/1"}
+
+		_, err := client.BuildConsent(s)
+
+		assert.EqualError(t, err, "actors.0: identifier must have the form urn:oid:system:value, got [Organization/1]")
+	})
+
+	t.Run("class must have a system", func(t *testing.T) {
+		s := spec()
+		s.Classes = []string{"MEDICAL"}
+
+		_, err := client.BuildConsent(s)
+
+		assert.EqualError(t, err, "classes.0: class must have the form urn:oid:system:code or system#code, got [MEDICAL]")
+	})
+
+	t.Run("end before start", func(t *testing.T) {
+		s := spec()
+		end := start.Add(-time.Hour)
+		s.End = &end
+
+		_, err := client.BuildConsent(s)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("class must be registered", func(t *testing.T) {
+		s := spec()
+		s.Classes = []string{"urn:oid:1.3.6.1.4.1.54851.1:UNKNOWN"}
+
+		_, err := client.BuildConsent(s)
+
+		assert.EqualError(t, err, "built consent record is invalid: provision.provision.0.class.0: class urn:oid:1.3.6.1.4.1.54851.1:UNKNOWN is not registered")
+	})
+
+	t.Run("node policy applies", func(t *testing.T) {
+		v := &Validator{}
+		v.Config.Policy.Custodians = "urn:oid:2.16.840.1.113883.2.4.6.1:00000001"
+		v.Config.Policy.Contenttypes = "application/json+irma"
+		if err := v.Configure(); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := v.BuildConsent(spec())
+
+		assert.EqualError(t, err, "built consent record is invalid: "+
+			"organization.0.identifier: custodian urn:oid:2.16.840.1.113883.2.4.6.1:00000000 is not allowed by this node; "+
+			"sourceAttachment.contentType: content type application/pdf is not allowed by this node")
+	})
+
+	t.Run("proof is required", func(t *testing.T) {
+		s := spec()
+		s.Proof = Attachment{}
+
+		_, err := client.BuildConsent(s)
+
+		assert.EqualError(t, err, "proof: contentType and data or url are required")
+	})
+}
@@ -0,0 +1,195 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/nuts-foundation/nuts-fhir-validation/schema"
+)
+
+// --classregistry config flag, a registry file that replaces the bundled registry
+const ConfigClassRegistry = "classregistry"
+
+// default use the bundled registry
+const ConfigClassRegistryDefault = ""
+
+// classRegistryAsset is the bundled registry with the Nuts consent classes
+const classRegistryAsset = "classes/nuts.json"
+
+// fhirResourceTypes are the resource types of the bundled R4 schema, the only codes of a system with codeIsResourceType
+var fhirResourceTypes map[string]bool
+var fhirResourceTypesOnce sync.Once
+
+// ConsentClass is a registered class of data that can be used in a consent provision
+type ConsentClass struct {
+	// Class is the system and code combined, eg: urn:oid:1.3.6.1.4.1.54851.1:MEDICAL
+	Class       string `json:"class"`
+	Display     string `json:"display"`
+	Description string `json:"description,omitempty"`
+	// ResourceTypes are the FHIR resource types the class covers
+	ResourceTypes []string `json:"resourceTypes,omitempty"`
+	// Profiles are the urls of the FHIR profiles the class covers
+	Profiles []string `json:"profiles,omitempty"`
+}
+
+// ClassSystem is a code system of which every code is a registered class, eg: the FHIR resource types
+type ClassSystem struct {
+	System      string `json:"system"`
+	Description string `json:"description,omitempty"`
+	// CodeIsResourceType is true when a class of the system covers the FHIR resource type given by its code, only FHIR resource types are then registered
+	CodeIsResourceType bool `json:"codeIsResourceType,omitempty"`
+}
+
+// ClassRegistry lists the classes that can be used in consent provisions and what they cover
+type ClassRegistry struct {
+	Classes []ConsentClass `json:"classes"`
+	Systems []ClassSystem  `json:"systems,omitempty"`
+}
+
+// loadClassRegistry reads the registry from the given file or, when empty, the bundled registry. The content is written to the digest.
+func loadClassRegistry(path string, digest io.Writer) (ClassRegistry, error) {
+	var registry ClassRegistry
+
+	var data []byte
+	var err error
+	if path != ConfigClassRegistryDefault {
+		data, err = ioutil.ReadFile(path)
+	} else {
+		data, err = schema.Asset(classRegistryAsset)
+	}
+	if err != nil {
+		return registry, err
+	}
+	digest.Write(data)
+
+	if err := json.Unmarshal(data, &registry); err != nil {
+		return registry, fmt.Errorf("unable to load class registry: %w", err)
+	}
+	for i, c := range registry.Classes {
+		if _, err := codingFrom(c.Class); err != nil {
+			return registry, fmt.Errorf("unable to load class registry: classes.%d: %w", i, err)
+		}
+	}
+	for i, s := range registry.Systems {
+		if s.System == "" {
+			return registry, fmt.Errorf("unable to load class registry: systems.%d: system is required", i)
+		}
+	}
+	return registry, nil
+}
+
+// Find returns the registered class, a class of a registered system is returned with the resource type it covers.
+// For a system with codeIsResourceType the code must be a FHIR resource type.
+func (r ClassRegistry) Find(class string) (ConsentClass, bool) {
+	for _, c := range r.Classes {
+		if c.Class == class {
+			return c, true
+		}
+	}
+
+	coding, err := codingFrom(class)
+	if err != nil {
+		return ConsentClass{}, false
+	}
+	for _, s := range r.Systems {
+		if s.System != coding.System {
+			continue
+		}
+		c := ConsentClass{Class: class, Display: coding.Code, Description: s.Description}
+		if s.CodeIsResourceType {
+			if !isResourceType(coding.Code) {
+				return ConsentClass{}, false
+			}
+			c.ResourceTypes = []string{coding.Code}
+		}
+		return c, true
+	}
+	return ConsentClass{}, false
+}
+
+// isResourceType returns true when the code is a resource type of the bundled R4 schema
+func isResourceType(code string) bool {
+	fhirResourceTypesOnce.Do(func() {
+		var fhirSchema struct {
+			Discriminator struct {
+				Mapping map[string]string `json:"mapping"`
+			} `json:"discriminator"`
+		}
+		// the bundled schema is valid json, it is covered by the tests
+		_ = json.Unmarshal(schema.MustAsset("fhir.schema.json"), &fhirSchema)
+		fhirResourceTypes = map[string]bool{}
+		for resourceType := range fhirSchema.Discriminator.Mapping {
+			fhirResourceTypes[resourceType] = true
+		}
+	})
+	return fhirResourceTypes[code]
+}
+
+// validate checks that the provisions of a Consent only use registered classes
+func (r ClassRegistry) validate(resource map[string]interface{}) []ValidationError {
+	provision, ok := resource["provision"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return r.provision("provision", provision)
+}
+
+// provision checks the classes of the provision and its nested provisions
+func (r ClassRegistry) provision(field string, provision map[string]interface{}) []ValidationError {
+	var errs []ValidationError
+	for i, class := range objects(provision["class"]) {
+		system, _ := class["system"].(string)
+		code, _ := class["code"].(string)
+		if system == "" || code == "" {
+			// reported by the extractors
+			continue
+		}
+		value := Coding{System: system, Code: code}.String()
+		if _, ok := r.Find(value); !ok {
+			classField := fmt.Sprintf("%s.class.%d", field, i)
+			errs = append(errs, ValidationError{
+				Type:     TypeConstraint,
+				Code:     codeFromField("nuts", classField, "", "unregistered"),
+				Pointer:  pointerFromField(classField, ""),
+				Message:  fmt.Sprintf("%s: class %s is not registered", classField, value),
+				Actual:   value,
+				Severity: SeverityError,
+			})
+		}
+	}
+	for i, p := range objects(provision["provision"]) {
+		errs = append(errs, r.provision(fmt.Sprintf("%s.provision.%d", field, i), p)...)
+	}
+	return errs
+}
+
+// Classes returns the class registry of the active rules
+func (ve *Validator) Classes() (ClassRegistry, error) {
+	r := ve.current()
+	if r == nil {
+		return ClassRegistry{}, ErrNotConfigured
+	}
+	return r.classes, nil
+}
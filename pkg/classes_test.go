@@ -0,0 +1,184 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassRegistry_Find(t *testing.T) {
+	registry, err := loadClassRegistry(ConfigClassRegistryDefault, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("registered class", func(t *testing.T) {
+		class, ok := registry.Find("urn:oid:1.3.6.1.4.1.54851.1:MEDICAL")
+
+		assert.True(t, ok)
+		assert.Equal(t, "Medical", class.Display)
+		assert.Contains(t, class.ResourceTypes, "Observation")
+	})
+
+	t.Run("class of a registered system", func(t *testing.T) {
+		class, ok := registry.Find("http://hl7.org/fhir/resource-types#Observation")
+
+		assert.True(t, ok)
+		assert.Equal(t, []string{"Observation"}, class.ResourceTypes)
+	})
+
+	t.Run("unknown resource type of a registered system", func(t *testing.T) {
+		_, ok := registry.Find("http://hl7.org/fhir/resource-types#Foo")
+
+		assert.False(t, ok)
+	})
+
+	t.Run("unregistered class", func(t *testing.T) {
+		_, ok := registry.Find("urn:oid:1.3.6.1.4.1.54851.1:MENTAL")
+
+		assert.False(t, ok)
+	})
+
+	t.Run("malformed class", func(t *testing.T) {
+		_, ok := registry.Find("MEDICAL")
+
+		assert.False(t, ok)
+	})
+}
+
+func TestLoadClassRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "classes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(content string) string {
+		path := filepath.Join(dir, "classes.json")
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("registry file replaces the bundled registry", func(t *testing.T) {
+		registry, err := loadClassRegistry(write(`{"classes": [{"class": "urn:oid:1.3.6.1.4.1.54851.1:MENTAL", "display": "Mental"}]}`), ioutil.Discard)
+
+		if assert.NoError(t, err) {
+			_, ok := registry.Find("urn:oid:1.3.6.1.4.1.54851.1:MENTAL")
+			assert.True(t, ok)
+			_, ok = registry.Find("urn:oid:1.3.6.1.4.1.54851.1:MEDICAL")
+			assert.False(t, ok)
+		}
+	})
+
+	t.Run("malformed class", func(t *testing.T) {
+		_, err := loadClassRegistry(write(`{"classes": [{"class": "MENTAL"}]}`), ioutil.Discard)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("system without url", func(t *testing.T) {
+		_, err := loadClassRegistry(write(`{"classes": [], "systems": [{"description": "none"}]}`), ioutil.Discard)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		_, err := loadClassRegistry(write(`{`), ioutil.Discard)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := loadClassRegistry(filepath.Join(dir, "missing.json"), ioutil.Discard)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestValidator_ValidateAgainstClassRegistry(t *testing.T) {
+	bytes, _ := ioutil.ReadFile("../examples/observation_consent.json")
+	validator := validationBackend()
+
+	withClass := func(system string, code string) []byte {
+		var consent map[string]interface{}
+		_ = json.Unmarshal(bytes, &consent)
+		provision := consent["provision"].(map[string]interface{})["provision"].([]interface{})[0].(map[string]interface{})
+		provision["class"] = append(provision["class"].([]interface{}), map[string]interface{}{"system": system, "code": code})
+		data, _ := json.Marshal(consent)
+		return data
+	}
+
+	t.Run("registered classes", func(t *testing.T) {
+		valid, errs, err := validator.ValidateAgainstSchema(bytes)
+
+		assert.NoError(t, err)
+		assert.True(t, valid)
+		assert.Empty(t, errs)
+	})
+
+	t.Run("unregistered class", func(t *testing.T) {
+		valid, errs, _ := validator.ValidateAgainstSchema(withClass("urn:oid:1.3.6.1.4.1.54851.1", "MENTAL"))
+
+		assert.False(t, valid)
+		assert.Equal(t, []ValidationError{{
+			Type:     TypeConstraint,
+			Code:     "nuts.provision.provision.class-unregistered",
+			Pointer:  "/provision/provision/0/class/2",
+			Message:  "provision.provision.0.class.2: class urn:oid:1.3.6.1.4.1.54851.1:MENTAL is not registered",
+			Actual:   "urn:oid:1.3.6.1.4.1.54851.1:MENTAL",
+			Severity: SeverityError,
+		}}, errs)
+	})
+
+	t.Run("unknown resource type", func(t *testing.T) {
+		valid, errs, _ := validator.ValidateAgainstSchema(withClass("http://hl7.org/fhir/resource-types", "Foo"))
+
+		assert.False(t, valid)
+		if assert.Len(t, errs, 1) {
+			assert.Equal(t, "provision.provision.0.class.2: class http://hl7.org/fhir/resource-types#Foo is not registered", errs[0].Message)
+		}
+	})
+
+	t.Run("class registry of the configuration", func(t *testing.T) {
+		v := &Validator{}
+		v.Config.Classregistry = "../schema/classes/nuts.json"
+		if !assert.NoError(t, v.Configure()) {
+			return
+		}
+		registry, err := v.Classes()
+
+		assert.NoError(t, err)
+		assert.Len(t, registry.Classes, 2)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		_, err := (&Validator{}).Classes()
+
+		assert.Equal(t, ErrNotConfigured, err)
+	})
+}
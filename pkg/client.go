@@ -19,20 +19,37 @@
 
 package pkg
 
-import "github.com/sirupsen/logrus"
+import (
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
 
 // ValidatorClient is the main interface for the Validator
 type ValidatorClient interface {
 	// ValidateAgainstSchemaConsentAt validates the consent record at the given location (on disk)
-	ValidateAgainstSchemaConsentAt(source string) (bool, []string, error)
+	ValidateAgainstSchemaConsentAt(source string) (bool, []ValidationError, error)
 
 	// ValidateAgainstSchema Validates the given consent record against the schema
-	ValidateAgainstSchema(json []byte) (bool, []string, error)
+	ValidateAgainstSchema(json []byte) (bool, []ValidationError, error)
+
+	// ValidateAgainstPolicy checks the given (schema valid) consent record against the policy of this node
+	ValidateAgainstPolicy(json []byte) ([]ValidationError, error)
+
+	// Validate runs all validation stages on the given consent record, the report holds the errors or the simplified consent
+	Validate(json []byte) (*ValidationReport, error)
+
+	// ValidateFile runs all validation stages on the consent record at the given location (on disk)
+	ValidateFile(source string) (*ValidationReport, error)
+
+	// ValidateReader runs all validation stages on the consent record read from the reader
+	ValidateReader(reader io.Reader) (*ValidationReport, error)
 }
 
-// NewValidatorClient returns the default Validator client, either a Local- or RemoteClient
+// NewValidatorClient returns the local Validator client.
+//
+// Deprecated: use client.NewValidatorClient, which returns a remote client when the engine runs in client mode
 func NewValidatorClient() ValidatorClient {
-	// temporary
 	validator := ValidatorInstance()
 	if err := validator.Configure(); err != nil {
 		logrus.Panic(err)
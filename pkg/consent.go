@@ -0,0 +1,298 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/thedevsaddam/gojsonq/v2"
+)
+
+// Consent is the typed model of the FHIR Consent fields used by the Nuts consent profile
+type Consent struct {
+	ResourceType     string            `json:"resourceType"`
+	ID               string            `json:"id,omitempty"`
+	Meta             *Meta             `json:"meta,omitempty"`
+	Scope            *CodeableConcept  `json:"scope,omitempty"`
+	Category         []CodeableConcept `json:"category,omitempty"`
+	Patient          *Reference        `json:"patient,omitempty"`
+	DateTime         string            `json:"dateTime,omitempty"`
+	Performer        []Reference       `json:"performer,omitempty"`
+	Organization     []Reference       `json:"organization,omitempty"`
+	SourceAttachment *Attachment       `json:"sourceAttachment,omitempty"`
+	Verification     []Verification    `json:"verification,omitempty"`
+	PolicyRule       *CodeableConcept  `json:"policyRule,omitempty"`
+	Provision        *Provision        `json:"provision,omitempty"`
+}
+
+// Meta holds the version information of a resource
+type Meta struct {
+	VersionID   string `json:"versionId,omitempty"`
+	LastUpdated string `json:"lastUpdated,omitempty"`
+}
+
+// Coding is a code from a code system
+type Coding struct {
+	System string `json:"system,omitempty"`
+	Code   string `json:"code,omitempty"`
+}
+
+// CodeableConcept is a list of codings for the same concept
+type CodeableConcept struct {
+	Coding []Coding `json:"coding,omitempty"`
+}
+
+// FHIRIdentifier is the FHIR representation of an identifier as system/value pair
+type FHIRIdentifier struct {
+	System string `json:"system,omitempty"`
+	Value  string `json:"value,omitempty"`
+}
+
+// Reference refers to another resource, Nuts only uses logical references through an identifier
+type Reference struct {
+	Reference  string          `json:"reference,omitempty"`
+	Type       string          `json:"type,omitempty"`
+	Identifier *FHIRIdentifier `json:"identifier,omitempty"`
+	Display    string          `json:"display,omitempty"`
+}
+
+// Attachment refers to the proof of the consent
+type Attachment struct {
+	ContentType string `json:"contentType,omitempty"`
+	Data        string `json:"data,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Hash        string `json:"hash,omitempty"`
+	Title       string `json:"title,omitempty"`
+}
+
+// Verification records who verified the consent
+type Verification struct {
+	Verified         bool       `json:"verified"`
+	VerifiedWith     *Reference `json:"verifiedWith,omitempty"`
+	VerificationDate string     `json:"verificationDate,omitempty"`
+}
+
+// Period is a time range with an optional end, start and end are kept as FHIR dateTime strings
+type Period struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+// ProvisionActor is an actor the provision applies to
+type ProvisionActor struct {
+	Role      CodeableConcept `json:"role"`
+	Reference Reference       `json:"reference"`
+}
+
+// Provision holds the extend of the consent, provisions can be nested
+type Provision struct {
+	Type       string            `json:"type,omitempty"`
+	Period     *Period           `json:"period,omitempty"`
+	Actor      []ProvisionActor  `json:"actor,omitempty"`
+	Action     []CodeableConcept `json:"action,omitempty"`
+	Class      []Coding          `json:"class,omitempty"`
+	DataPeriod *Period           `json:"dataPeriod,omitempty"`
+	Provision  []Provision       `json:"provision,omitempty"`
+}
+
+// ParseConsent decodes the given json into a Consent. It fails when the json is broken or not a Consent.
+func ParseConsent(data []byte) (*Consent, error) {
+	consent := &Consent{}
+	if err := json.Unmarshal(data, consent); err != nil {
+		return nil, err
+	}
+
+	if consent.ResourceType != consentResourceType {
+		return nil, fmt.Errorf("resourceType must be %s, got [%s]", consentResourceType, consent.ResourceType)
+	}
+
+	return consent, nil
+}
+
+// consentFrom decodes the document of the jsonq source into a Consent
+func consentFrom(jsonq *gojsonq.JSONQ) (*Consent, error) {
+	data, err := json.Marshal(jsonq.Copy().Reset().Get())
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseConsent(data)
+}
+
+// String returns the identifier as system:value
+func (i *FHIRIdentifier) String() string {
+	if i == nil {
+		return ""
+	}
+	return fmt.Sprintf(concatIdFormat, i.System, i.Value)
+}
+
+// identifierFrom returns the system:value of the identifier of the reference, a reference without a complete identifier results in an error
+func identifierFrom(field string, reference *Reference) (string, error) {
+	if reference == nil {
+		return "", missingValue(field)
+	}
+	if reference.Identifier == nil {
+		if reference.Reference != "" {
+			return "", invalidValue(field+".identifier", reference.Reference)
+		}
+		return "", missingValue(field + ".identifier")
+	}
+	if reference.Identifier.System == "" {
+		return "", missingValue(field + ".identifier.system")
+	}
+	if reference.Identifier.Value == "" {
+		return "", missingValue(field + ".identifier.value")
+	}
+	return reference.Identifier.String(), nil
+}
+
+// Subject returns the patient identifier
+func (c *Consent) Subject() (string, error) {
+	return identifierFrom("patient", c.Patient)
+}
+
+// Custodian returns the identifier of the (first) organization
+func (c *Consent) Custodian() (string, error) {
+	if len(c.Organization) == 0 {
+		return "", missingValue("organization")
+	}
+	return identifierFrom("organization.0", &c.Organization[0])
+}
+
+// Actors returns the identifiers of the actors of the top level provision.
+// An actor referred to by a literal reference instead of an identifier results in an error.
+func (c *Consent) Actors() ([]Identifier, error) {
+	if c.Provision == nil {
+		return nil, missingValue("provision")
+	}
+
+	var actors []Identifier
+	for i, actor := range c.Provision.Actor {
+		identifier, err := identifierFrom(fmt.Sprintf("provision.actor.%d.reference", i), &actor.Reference)
+		if err != nil {
+			return nil, err
+		}
+		actors = append(actors, Identifier(identifier))
+	}
+	return actors, nil
+}
+
+// Period returns a tuple of time pointers (validFrom, validTo) of the top level provision where the validTo may be nil
+func (c *Consent) Period() ([]*time.Time, error) {
+	if c.Provision == nil || c.Provision.Period == nil {
+		return nil, missingValue("provision.period")
+	}
+	period := c.Provision.Period
+
+	if period.Start == "" {
+		return nil, missingValue("provision.period.start")
+	}
+	start, _, err := parseDateTime(period.Start)
+	if err != nil {
+		return nil, invalidValue("provision.period.start", period.Start)
+	}
+	if period.End == "" {
+		return []*time.Time{&start, nil}, nil
+	}
+
+	_, end, err := parseDateTime(period.End)
+	if err != nil {
+		return nil, invalidValue("provision.period.end", period.End)
+	}
+	return []*time.Time{&start, &end}, nil
+}
+
+// parseDateTime parses a FHIR dateTime with the precision of a year (2016), month (2016-06), day (2016-06-23) or second (RFC3339).
+// It returns the first and last instant the value covers, eg: 2016-06 covers 2016-06-01T00:00:00Z up to the last nanosecond of June.
+// A partial date has no time zone, it is taken as UTC.
+func parseDateTime(value string) (time.Time, time.Time, error) {
+	var layout string
+	var next func(time.Time) time.Time
+	switch len(value) {
+	case len("2006"):
+		layout, next = "2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }
+	case len("2006-01"):
+		layout, next = "2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	case len("2006-01-02"):
+		layout, next = "2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	default:
+		t, err := time.Parse(time.RFC3339, value)
+		return t, t, err
+	}
+
+	from, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return from, next(from).Add(-time.Nanosecond), nil
+}
+
+// Version returns the meta.versionId
+func (c *Consent) Version() (string, error) {
+	if c.Meta == nil || c.Meta.VersionID == "" {
+		return "", missingValue("meta.versionId")
+	}
+	return c.Meta.VersionID, nil
+}
+
+// LastUpdated returns the meta.lastUpdated
+func (c *Consent) LastUpdated() (time.Time, error) {
+	if c.Meta == nil || c.Meta.LastUpdated == "" {
+		return time.Time{}, missingValue("meta.lastUpdated")
+	}
+	lastUpdated, _, err := parseDateTime(c.Meta.LastUpdated)
+	if err != nil {
+		return time.Time{}, invalidValue("meta.lastUpdated", c.Meta.LastUpdated)
+	}
+	return lastUpdated, nil
+}
+
+// DataClasses returns the classes that are permitted to at least one actor, in order of appearance. Classes excluded by a deny provision are left out.
+// It combines the system and code field to a single string using the correct divider (: or #) based on the type of system
+func (c *Consent) DataClasses() ([]string, error) {
+	rules, err := c.rules()
+	if err != nil {
+		return nil, err
+	}
+
+	actors, permitted := evaluate(rules)
+	var dataClasses []string
+	for _, actor := range actors {
+		for _, class := range permitted[actor] {
+			if !contains(dataClasses, class) {
+				dataClasses = append(dataClasses, class)
+			}
+		}
+	}
+	return dataClasses, nil
+}
+
+// String returns the coding as single string, urn:oid systems use : as divider, others #
+func (c Coding) String() string {
+	divider := "#"
+	if strings.Index(c.System, "urn:oid") != -1 {
+		divider = ":"
+	}
+	return fmt.Sprintf("%s%s%s", c.System, divider, c.Code)
+}
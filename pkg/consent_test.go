@@ -0,0 +1,134 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConsent(t *testing.T) {
+	t.Run("observation consent", func(t *testing.T) {
+		bytes, _ := ioutil.ReadFile("../examples/observation_consent.json")
+
+		consent, err := ParseConsent(bytes)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		subject, _ := consent.Subject()
+		assert.Equal(t, "urn:oid:2.16.840.1.113883.2.4.6.3:999999990", subject)
+		custodian, _ := consent.Custodian()
+		assert.Equal(t, "urn:oid:2.16.840.1.113883.2.4.6.1:00000000", custodian)
+		actors, _ := consent.Actors()
+		assert.Equal(t, []Identifier{"urn:oid:2.16.840.1.113883.2.4.6.1:00000007"}, actors)
+		version, _ := consent.Version()
+		assert.Equal(t, "1", version)
+		classes, _ := consent.DataClasses()
+		assert.Equal(t, []string{"http://hl7.org/fhir/resource-types#Observation", "urn:oid:1.3.6.1.4.1.54851.1:MEDICAL"}, classes)
+		assert.Equal(t, "application/pdf", consent.SourceAttachment.ContentType)
+		assert.True(t, consent.Verification[0].Verified)
+		assert.Equal(t, "OPTIN", consent.PolicyRule.Coding[0].Code)
+		assert.Equal(t, "permit", consent.Provision.Provision[0].Type)
+
+		period, err := consent.Period()
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2016, 6, 23, 17, 2, 33, 0, time.FixedZone("", 36000)), *period[0])
+		assert.Equal(t, time.Date(2016, 6, 23, 17, 32, 33, 0, time.FixedZone("", 36000)), *period[1])
+	})
+
+	t.Run("other resource type returns error", func(t *testing.T) {
+		_, err := ParseConsent([]byte(`{"resourceType": "Patient"}`))
+
+		assert.EqualError(t, err, "resourceType must be Consent, got [Patient]")
+	})
+
+	t.Run("broken json returns error", func(t *testing.T) {
+		_, err := ParseConsent([]byte(`{`))
+
+		assert.Error(t, err)
+	})
+
+	t.Run("missing fields return errors", func(t *testing.T) {
+		consent, _ := ParseConsent([]byte(`{"resourceType": "Consent"}`))
+
+		_, err := consent.Subject()
+		assert.EqualError(t, err, "patient: value is missing")
+		_, err = consent.Custodian()
+		assert.EqualError(t, err, "organization: value is missing")
+		_, err = consent.Actors()
+		assert.EqualError(t, err, "provision: value is missing")
+		_, err = consent.Version()
+		assert.EqualError(t, err, "meta.versionId: value is missing")
+		_, err = consent.DataClasses()
+		assert.EqualError(t, err, "provision: value is missing")
+		_, err = consent.Period()
+		assert.EqualError(t, err, "provision.period: value is missing")
+	})
+}
+
+func TestParseDateTime(t *testing.T) {
+	amsterdam := time.FixedZone("", 2*60*60)
+	for value, expected := range map[string][2]time.Time{
+		"2016":                          {time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2016, 12, 31, 23, 59, 59, 999999999, time.UTC)},
+		"2016-02":                       {time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2016, 2, 29, 23, 59, 59, 999999999, time.UTC)},
+		"2016-06-23":                    {time.Date(2016, 6, 23, 0, 0, 0, 0, time.UTC), time.Date(2016, 6, 23, 23, 59, 59, 999999999, time.UTC)},
+		"2016-06-23T17:02:33+02:00":     {time.Date(2016, 6, 23, 17, 2, 33, 0, amsterdam), time.Date(2016, 6, 23, 17, 2, 33, 0, amsterdam)},
+		"2015-02-07T13:28:17.239+02:00": {time.Date(2015, 2, 7, 13, 28, 17, 239000000, amsterdam), time.Date(2015, 2, 7, 13, 28, 17, 239000000, amsterdam)},
+	} {
+		from, to, err := parseDateTime(value)
+
+		if assert.NoError(t, err, value) {
+			assert.True(t, expected[0].Equal(from), value)
+			assert.True(t, expected[1].Equal(to), value)
+		}
+	}
+
+	for _, value := range []string{"", "16", "2016-6", "2016-06-2", "2016-13", "2016-06-23T17:02", "23-06-2016"} {
+		_, _, err := parseDateTime(value)
+
+		assert.Error(t, err, value)
+	}
+}
+
+func TestErrorFrom(t *testing.T) {
+	t.Run("extraction error points to the field", func(t *testing.T) {
+		ve := ErrorFrom(invalidValue("provision.actor.0.reference.identifier", "Organization/1"))
+
+		assert.Equal(t, ValidationError{
+			Type:     TypeConstraint,
+			Code:     "extract.provision.actor.reference.identifier-invalid",
+			Pointer:  "/provision/actor/0/reference/identifier",
+			Message:  "provision.actor.0.reference.identifier: value [Organization/1] can not be extracted",
+			Actual:   "Organization/1",
+			Severity: SeverityError,
+		}, ve)
+	})
+
+	t.Run("other errors are syntax errors", func(t *testing.T) {
+		ve := ErrorFrom(errors.New("unexpected EOF"))
+
+		assert.Equal(t, TypeSyntax, ve.Type)
+	})
+}
@@ -0,0 +1,255 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Decisions
+const (
+	DecisionPermit = "permit"
+	DecisionDeny   = "deny"
+)
+
+// defaultAction is used when a DecisionRequest does not specify an action
+const defaultAction = "access"
+
+// DecisionRequest asks whether an actor may perform an action on a class of data of a subject held by a custodian at a given moment
+type DecisionRequest struct {
+	Custodian string
+	Subject   string
+	Actor     Identifier
+	Class     string
+	// Action from http://terminology.hl7.org/CodeSystem/consentaction, access when empty
+	Action string
+	// Timestamp of the access, now when zero
+	Timestamp time.Time
+}
+
+// MatchedProvision is a provision that applies to the DecisionRequest
+type MatchedProvision struct {
+	// Consent is the index of the consent record in the list given to Decide
+	Consent int
+	// Provision is the "provision.provision.0" style location of the provision in the consent record, policyRule for an OPTOUT
+	Provision string
+	// Type of the provision: permit or deny
+	Type string
+}
+
+// Decision is the result of Decide
+type Decision struct {
+	// Decision is either permit or deny
+	Decision string
+	// Provisions that matched the request, both permit and deny
+	Provisions []MatchedProvision
+}
+
+// Decide evaluates the request against the consent records, records for other custodians or subjects are ignored.
+// When the list holds several versions of a record, with the same id, only the version with the highest versionId is evaluated.
+// Access is permitted when a permit provision matches and no deny provision matches, a deny always wins. An OPTOUT record denies everything.
+// A provision matches when the actor, class and action are part of it, including the values it inherits from its parent,
+// and the timestamp falls in its period and the periods of all its parents.
+func Decide(consents []*Consent, request DecisionRequest) (Decision, error) {
+	if request.Action == "" {
+		request.Action = defaultAction
+	}
+	if request.Timestamp.IsZero() {
+		request.Timestamp = time.Now()
+	}
+
+	skip, err := superseded(consents)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	decision := Decision{Decision: DecisionDeny}
+	permit, deny := false, false
+	for i, consent := range consents {
+		if skip[i] {
+			continue
+		}
+		applies, err := consent.appliesTo(request)
+		if err != nil {
+			return Decision{}, fmt.Errorf("consent %d: %w", i, err)
+		}
+		if !applies {
+			continue
+		}
+
+		if consent.policy() == policyOptOut {
+			deny = true
+			decision.Provisions = append(decision.Provisions, MatchedProvision{Consent: i, Provision: "policyRule", Type: ProvisionDeny})
+			continue
+		}
+
+		rules, err := consent.rules()
+		if err != nil {
+			return Decision{}, fmt.Errorf("consent %d: %w", i, err)
+		}
+		for _, rule := range rules {
+			matches, err := rule.matches(request)
+			if err != nil {
+				return Decision{}, fmt.Errorf("consent %d: %w", i, err)
+			}
+			if !matches {
+				continue
+			}
+			permit = permit || rule.kind == ProvisionPermit
+			deny = deny || rule.kind == ProvisionDeny
+			decision.Provisions = append(decision.Provisions, MatchedProvision{Consent: i, Provision: rule.field, Type: rule.kind})
+		}
+	}
+
+	if permit && !deny {
+		decision.Decision = DecisionPermit
+	}
+	return decision, nil
+}
+
+// appliesTo returns true when the consent is given by the subject of the request for the custodian of the request
+func (c *Consent) appliesTo(request DecisionRequest) (bool, error) {
+	subject, err := c.Subject()
+	if err != nil {
+		return false, err
+	}
+	custodian, err := c.Custodian()
+	if err != nil {
+		return false, err
+	}
+	return subject == request.Subject && custodian == request.Custodian, nil
+}
+
+// policy returns the code of the Nuts policyRule: OPTIN or OPTOUT
+func (c *Consent) policy() string {
+	if c.PolicyRule == nil {
+		return ""
+	}
+	for _, coding := range c.PolicyRule.Coding {
+		if coding.System == PolicyRuleSystem {
+			return coding.Code
+		}
+	}
+	return ""
+}
+
+// matches returns true when the rule has a type and covers the actor, class, action and timestamp of the request.
+// A rule without actions covers every action.
+func (r provisionRule) matches(request DecisionRequest) (bool, error) {
+	if r.kind == "" {
+		return false, nil
+	}
+	if !containsIdentifier(r.actors, request.Actor) || !contains(r.classes, request.Class) {
+		return false, nil
+	}
+	if len(r.actions) > 0 && !contains(r.actions, request.Action) {
+		return false, nil
+	}
+	for _, p := range r.periods {
+		if within, err := p.contains(request.Timestamp); err != nil || !within {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// contains returns true when the timestamp falls in the period, an end given as partial date covers the whole year, month or day
+func (p fieldPeriod) contains(timestamp time.Time) (bool, error) {
+	start, _, err := parseDateTime(p.period.Start)
+	if err != nil {
+		return false, invalidValue(p.field+".start", p.period.Start)
+	}
+	if timestamp.Before(start) {
+		return false, nil
+	}
+	if p.period.End == "" {
+		return true, nil
+	}
+	_, end, err := parseDateTime(p.period.End)
+	if err != nil {
+		return false, invalidValue(p.field+".end", p.period.End)
+	}
+	return !timestamp.After(end), nil
+}
+
+// superseded returns the indices of the consent records for which a later version of the same record, with the same id, is in the list.
+// Records without id can not be related to their other versions, they are never superseded.
+func superseded(consents []*Consent) (map[int]bool, error) {
+	result := map[int]bool{}
+	latest := map[string]int{}
+	for i, consent := range consents {
+		if consent.ID == "" {
+			continue
+		}
+		j, ok := latest[consent.ID]
+		if !ok {
+			latest[consent.ID] = i
+			continue
+		}
+		newer, err := consent.newerThan(consents[j])
+		if err != nil {
+			return nil, fmt.Errorf("consent %d: %w", i, err)
+		}
+		if newer {
+			result[j] = true
+			latest[consent.ID] = i
+		} else {
+			result[i] = true
+		}
+	}
+	return result, nil
+}
+
+// newerThan returns true when the versionId of the consent is higher than the versionId of the other version
+func (c *Consent) newerThan(other *Consent) (bool, error) {
+	version, err := c.versionNumber()
+	if err != nil {
+		return false, err
+	}
+	otherVersion, err := other.versionNumber()
+	if err != nil {
+		return false, err
+	}
+	return version > otherVersion, nil
+}
+
+// versionNumber returns the meta.versionId as number
+func (c *Consent) versionNumber() (int, error) {
+	version, err := c.Version()
+	if err != nil {
+		return 0, err
+	}
+	number, err := strconv.Atoi(version)
+	if err != nil {
+		return 0, invalidValue("meta.versionId", version)
+	}
+	return number, nil
+}
+
+func containsIdentifier(list []Identifier, value Identifier) bool {
+	for _, l := range list {
+		if l == value {
+			return true
+		}
+	}
+	return false
+}
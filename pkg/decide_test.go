@@ -0,0 +1,172 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecide(t *testing.T) {
+	bytes, _ := ioutil.ReadFile("../examples/observation_consent.json")
+	consent, _ := ParseConsent(bytes)
+	request := DecisionRequest{
+		Custodian: "urn:oid:2.16.840.1.113883.2.4.6.1:00000000",
+		Subject:   "urn:oid:2.16.840.1.113883.2.4.6.3:999999990",
+		Actor:     "urn:oid:2.16.840.1.113883.2.4.6.1:00000007",
+		Class:     "urn:oid:1.3.6.1.4.1.54851.1:MEDICAL",
+		Timestamp: time.Date(2016, 6, 23, 17, 10, 0, 0, time.FixedZone("", 36000)),
+	}
+
+	t.Run("permit within period", func(t *testing.T) {
+		decision, err := Decide([]*Consent{consent}, request)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, DecisionPermit, decision.Decision)
+		assert.Equal(t, []MatchedProvision{{Consent: 0, Provision: "provision.provision.0", Type: ProvisionPermit}}, decision.Provisions)
+	})
+
+	t.Run("deny after period", func(t *testing.T) {
+		r := request
+		r.Timestamp = time.Date(2016, 6, 24, 0, 0, 0, 0, time.UTC)
+
+		decision, _ := Decide([]*Consent{consent}, r)
+
+		assert.Equal(t, DecisionDeny, decision.Decision)
+		assert.Empty(t, decision.Provisions)
+	})
+
+	t.Run("period with dates covers the whole end day", func(t *testing.T) {
+		dates, _ := ParseConsent([]byte(strings.Replace(string(bytes), `"start": "2016-06-23T17:02:33+10:00",
+      "end": "2016-06-23T17:32:33+10:00"`, `"start": "2016-06", "end": "2016-06-24"`, 1)))
+		r := request
+		r.Timestamp = time.Date(2016, 6, 24, 23, 0, 0, 0, time.UTC)
+
+		decision, err := Decide([]*Consent{dates}, r)
+
+		assert.NoError(t, err)
+		assert.Equal(t, DecisionPermit, decision.Decision)
+	})
+
+	t.Run("deny for other actor, class or action", func(t *testing.T) {
+		other := request
+		other.Actor = "urn:oid:2.16.840.1.113883.2.4.6.1:00000008"
+		decision, _ := Decide([]*Consent{consent}, other)
+		assert.Equal(t, DecisionDeny, decision.Decision)
+
+		other = request
+		other.Class = "urn:oid:1.3.6.1.4.1.54851.1:SOCIAL"
+		decision, _ = Decide([]*Consent{consent}, other)
+		assert.Equal(t, DecisionDeny, decision.Decision)
+
+		other = request
+		other.Action = "disclose"
+		decision, _ = Decide([]*Consent{consent}, other)
+		assert.Equal(t, DecisionDeny, decision.Decision)
+	})
+
+	t.Run("consents for other subjects are ignored", func(t *testing.T) {
+		r := request
+		r.Subject = "urn:oid:2.16.840.1.113883.2.4.6.3:999999991"
+
+		decision, _ := Decide([]*Consent{consent}, r)
+
+		assert.Equal(t, DecisionDeny, decision.Decision)
+		assert.Empty(t, decision.Provisions)
+	})
+
+	t.Run("deny in another consent wins", func(t *testing.T) {
+		deny, _ := ParseConsent([]byte(strings.Replace(string(bytes), `"type": "permit"`, `"type": "deny"`, 1)))
+
+		decision, _ := Decide([]*Consent{consent, deny}, request)
+
+		assert.Equal(t, DecisionDeny, decision.Decision)
+		assert.Equal(t, []MatchedProvision{
+			{Consent: 0, Provision: "provision.provision.0", Type: ProvisionPermit},
+			{Consent: 1, Provision: "provision.provision.0", Type: ProvisionDeny},
+		}, decision.Provisions)
+	})
+
+	t.Run("nested period narrows the period of the parent", func(t *testing.T) {
+		nested, _ := ParseConsent([]byte(strings.Replace(string(bytes), `"type": "permit",`, `"type": "permit",
+        "period": {"start": "2016-06-23T17:20:00+10:00", "end": "2016-07"},`, 1)))
+		r := request
+		r.Timestamp = time.Date(2016, 6, 23, 17, 25, 0, 0, time.FixedZone("", 36000))
+
+		decision, err := Decide([]*Consent{nested}, request)
+		assert.NoError(t, err)
+		assert.Equal(t, DecisionDeny, decision.Decision)
+
+		decision, _ = Decide([]*Consent{nested}, r)
+		assert.Equal(t, DecisionPermit, decision.Decision)
+
+		r.Timestamp = time.Date(2016, 6, 23, 17, 40, 0, 0, time.FixedZone("", 36000))
+		decision, _ = Decide([]*Consent{nested}, r)
+		assert.Equal(t, DecisionDeny, decision.Decision)
+	})
+
+	t.Run("only the latest version of a record is evaluated", func(t *testing.T) {
+		withID := strings.Replace(string(bytes), `"resourceType": "Consent",`, `"resourceType": "Consent", "id": "1",`, 1)
+		v1, _ := ParseConsent([]byte(withID))
+		v2, _ := ParseConsent([]byte(strings.NewReplacer(`"versionId": "1"`, `"versionId": "10"`, `"type": "permit"`, `"type": "deny"`).Replace(withID)))
+
+		decision, err := Decide([]*Consent{v2, v1}, request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, DecisionDeny, decision.Decision)
+		assert.Equal(t, []MatchedProvision{{Consent: 0, Provision: "provision.provision.0", Type: ProvisionDeny}}, decision.Provisions)
+
+		decision, _ = Decide([]*Consent{v1}, request)
+		assert.Equal(t, DecisionPermit, decision.Decision)
+	})
+
+	t.Run("versions with an invalid versionId return error", func(t *testing.T) {
+		withID := strings.Replace(string(bytes), `"resourceType": "Consent",`, `"resourceType": "Consent", "id": "1",`, 1)
+		v1, _ := ParseConsent([]byte(withID))
+		invalid, _ := ParseConsent([]byte(strings.Replace(withID, `"versionId": "1"`, `"versionId": "a"`, 1)))
+
+		_, err := Decide([]*Consent{v1, invalid}, request)
+
+		assert.EqualError(t, err, "consent 1: meta.versionId: value [a] can not be extracted")
+	})
+
+	t.Run("opt out denies everything", func(t *testing.T) {
+		optOut, _ := ParseConsent([]byte(strings.Replace(string(bytes), `"code": "OPTIN"`, `"code": "OPTOUT"`, 1)))
+
+		decision, _ := Decide([]*Consent{consent, optOut}, request)
+
+		assert.Equal(t, DecisionDeny, decision.Decision)
+		assert.Equal(t, MatchedProvision{Consent: 1, Provision: "policyRule", Type: ProvisionDeny}, decision.Provisions[1])
+	})
+
+	t.Run("consent without subject returns error", func(t *testing.T) {
+		empty, _ := ParseConsent([]byte(`{"resourceType": "Consent"}`))
+
+		_, err := Decide([]*Consent{consent, empty}, request)
+
+		assert.EqualError(t, err, "consent 1: patient: value is missing")
+	})
+}
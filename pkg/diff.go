@@ -0,0 +1,151 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+)
+
+// Types of changes between two consent records
+const (
+	ChangeActorAdded   = "actor-added"
+	ChangeActorRemoved = "actor-removed"
+	ChangeClassAdded   = "class-added"
+	ChangeClassRemoved = "class-removed"
+	ChangePeriodStart  = "period-start"
+	ChangePeriodEnd    = "period-end"
+	ChangeSource       = "source"
+	ChangePolicyRule   = "policy-rule"
+)
+
+// Change is a difference between two consent records in terms of the extracted Nuts fields
+type Change struct {
+	// Type of change, eg: actor-added, class-removed or period-end
+	Type string `json:"type"`
+	// Old value, empty for additions and a period without end
+	Old string `json:"old,omitempty"`
+	// New value, empty for removals and a period without end
+	New string `json:"new,omitempty"`
+}
+
+// String returns an addition or removal as "type: value" and other changes as "type: old -> new", an empty value is shown as -
+func (c Change) String() string {
+	switch c.Type {
+	case ChangeActorAdded, ChangeClassAdded:
+		return fmt.Sprintf("%s: %s", c.Type, c.New)
+	case ChangeActorRemoved, ChangeClassRemoved:
+		return fmt.Sprintf("%s: %s", c.Type, c.Old)
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Type, orDash(c.Old), orDash(c.New))
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// Diff returns the changes between two consent records: added and removed actors and classes, period changes, source proof changes and policyRule changes.
+// Classes are the permitted classes as returned by DataClasses. An error is returned when a value can not be extracted from one of the records.
+func Diff(previous *Consent, current *Consent) ([]Change, error) {
+	var changes []Change
+
+	oa, err := previous.Actors()
+	if err != nil {
+		return nil, err
+	}
+	na, err := current.Actors()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range na {
+		if !containsIdentifier(oa, a) {
+			changes = append(changes, Change{Type: ChangeActorAdded, New: string(a)})
+		}
+	}
+	for _, a := range oa {
+		if !containsIdentifier(na, a) {
+			changes = append(changes, Change{Type: ChangeActorRemoved, Old: string(a)})
+		}
+	}
+
+	oc, err := previous.DataClasses()
+	if err != nil {
+		return nil, err
+	}
+	nc, err := current.DataClasses()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range nc {
+		if !contains(oc, c) {
+			changes = append(changes, Change{Type: ChangeClassAdded, New: c})
+		}
+	}
+	for _, c := range oc {
+		if !contains(nc, c) {
+			changes = append(changes, Change{Type: ChangeClassRemoved, Old: c})
+		}
+	}
+
+	op, err := previous.Period()
+	if err != nil {
+		return nil, err
+	}
+	np, err := current.Period()
+	if err != nil {
+		return nil, err
+	}
+	if !op[0].Equal(*np[0]) {
+		changes = append(changes, Change{Type: ChangePeriodStart, Old: formatTime(op[0]), New: formatTime(np[0])})
+	}
+	if (op[1] == nil) != (np[1] == nil) || (op[1] != nil && !op[1].Equal(*np[1])) {
+		changes = append(changes, Change{Type: ChangePeriodEnd, Old: formatTime(op[1]), New: formatTime(np[1])})
+	}
+
+	if os, ns := previous.SourceAttachment.String(), current.SourceAttachment.String(); os != ns {
+		changes = append(changes, Change{Type: ChangeSource, Old: os, New: ns})
+	}
+
+	if op, np := previous.policy(), current.policy(); op != np {
+		changes = append(changes, Change{Type: ChangePolicyRule, Old: op, New: np})
+	}
+
+	return changes, nil
+}
+
+// String returns the title and content type of the attachment with a digest of its content, url or hash
+func (a *Attachment) String() string {
+	if a == nil {
+		return ""
+	}
+	digest := sha256.Sum256([]byte(a.Data + a.URL + a.Hash))
+	return fmt.Sprintf("%s (%s, sha256:%x)", a.Title, a.ContentType, digest[:8])
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
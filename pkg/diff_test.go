@@ -0,0 +1,78 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	bytes, _ := ioutil.ReadFile("../examples/observation_consent.json")
+	old, _ := ParseConsent(bytes)
+
+	t.Run("same record has no changes", func(t *testing.T) {
+		changes, err := Diff(old, old)
+
+		assert.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("all changes", func(t *testing.T) {
+		json := string(bytes)
+		for _, r := range [][]string{
+			{`"value": "00000007"`, `"value": "00000008"`},
+			{`"system": "urn:oid:1.3.6.1.4.1.54851.1",
+            "code": "MEDICAL"`, `"system": "urn:oid:1.3.6.1.4.1.54851.1",
+            "code": "SOCIAL"`},
+			{`"end": "2016-06-23T17:32:33+10:00"`, `"end": "2016-06-23T17:12:33+10:00"`},
+			{`"data": "dhklauHAELrlg78OLg=="`, `"data": "ZGF0YQ=="`},
+			{`"code": "OPTIN"`, `"code": "OPTOUT"`},
+		} {
+			json = strings.Replace(json, r[0], r[1], 1)
+		}
+		new, _ := ParseConsent([]byte(json))
+
+		changes, err := Diff(old, new)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		var types []string
+		for _, c := range changes {
+			types = append(types, c.Type)
+		}
+		assert.Equal(t, []string{ChangeActorAdded, ChangeActorRemoved, ChangeClassAdded, ChangeClassRemoved, ChangePeriodEnd, ChangeSource, ChangePolicyRule}, types)
+		assert.Equal(t, "actor-added: urn:oid:2.16.840.1.113883.2.4.6.1:00000008", changes[0].String())
+		assert.Equal(t, "period-end: 2016-06-23T17:32:33+10:00 -> 2016-06-23T17:12:33+10:00", changes[4].String())
+		assert.Equal(t, Change{Type: ChangePolicyRule, Old: "OPTIN", New: "OPTOUT"}, changes[6])
+	})
+
+	t.Run("missing period returns error", func(t *testing.T) {
+		empty, _ := ParseConsent([]byte(`{"resourceType": "Consent", "provision": {}}`))
+
+		_, err := Diff(old, empty)
+
+		assert.EqualError(t, err, "provision.period: value is missing")
+	})
+}
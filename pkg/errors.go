@@ -0,0 +1,218 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Types of validation errors
+const (
+	// TypeSyntax is used when the json is broken
+	TypeSyntax = "syntax"
+	// TypeConstraint is used when the json is not a valid fhir resource or Nuts consent record
+	TypeConstraint = "constraint"
+	// TypePolicy is used when the current Nuts node settings do not allow the record
+	TypePolicy = "policy"
+	// TypeVersion is used when the record is not a valid update of the previous version
+	TypeVersion = "version"
+)
+
+// Severities of validation errors
+const (
+	// SeverityFatal is used when the document could not be processed at all
+	SeverityFatal = "fatal"
+	// SeverityError is used for violations that make the document invalid
+	SeverityError = "error"
+)
+
+// CodeSyntax is the error code used for documents that can not be parsed
+const CodeSyntax = "syntax"
+
+// ValidationError is a single problem found while validating a consent record
+type ValidationError struct {
+	// Type of error: syntax, constraint or policy
+	Type string `json:"type"`
+	// Code is a stable machine readable code, eg: required, enum or nuts.patient.display-forbidden
+	Code string `json:"code"`
+	// Pointer is the JSON Pointer (RFC 6901) to the offending field, "" points to the whole document
+	Pointer string `json:"pointer"`
+	// Message is the human readable description prefixed with the field
+	Message string `json:"message"`
+	// Expected is the value that was expected, if known
+	Expected string `json:"expected,omitempty"`
+	// Actual is the value that was found, if known
+	Actual string `json:"actual,omitempty"`
+	// Severity of the error: fatal or error
+	Severity string `json:"severity"`
+}
+
+// String returns the human readable message
+func (e ValidationError) String() string {
+	return e.Message
+}
+
+// SyntaxError creates the ValidationError for a document that could not be parsed
+func SyntaxError(err error) ValidationError {
+	return ValidationError{
+		Type:     TypeSyntax,
+		Code:     CodeSyntax,
+		Message:  err.Error(),
+		Severity: SeverityFatal,
+	}
+}
+
+// schemaError converts an error from the json schema validation
+func schemaError(re gojsonschema.ResultError) ValidationError {
+	ve := ValidationError{
+		Type:     TypeConstraint,
+		Code:     re.Type(),
+		Pointer:  pointerFromContext(re.Context()),
+		Message:  re.String(),
+		Severity: SeverityError,
+	}
+
+	details := re.Details()
+	if property, ok := details["property"]; ok && re.Type() == "required" {
+		ve.Pointer = fmt.Sprintf("%s/%s", ve.Pointer, escapePointer(fmt.Sprint(property)))
+	}
+	if expected, ok := details["expected"]; ok {
+		ve.Expected = fmt.Sprint(expected)
+	}
+	if allowed, ok := details["allowed"]; ok {
+		ve.Expected = fmt.Sprint(allowed)
+	}
+	if given, ok := details["given"]; ok {
+		ve.Actual = fmt.Sprint(given)
+	} else if ve.Expected != "" && re.Value() != nil {
+		ve.Actual = fmt.Sprint(re.Value())
+	}
+
+	return ve
+}
+
+// contextSeparator is used to split the gojsonschema context without conflicting with property names
+const contextSeparator = "\x1f"
+
+func pointerFromContext(context *gojsonschema.JsonContext) string {
+	if context == nil {
+		return ""
+	}
+
+	var pointer strings.Builder
+	for _, segment := range strings.Split(context.String(contextSeparator), contextSeparator)[1:] {
+		pointer.WriteString("/")
+		pointer.WriteString(escapePointer(segment))
+	}
+	return pointer.String()
+}
+
+// pointerFromField converts a "patient.identifier" style field (as used in the messages) and optional child property to a JSON Pointer
+func pointerFromField(field string, property string) string {
+	var pointer strings.Builder
+	for _, segment := range fieldSegments(field, property) {
+		pointer.WriteString("/")
+		pointer.WriteString(escapePointer(segment))
+	}
+	return pointer.String()
+}
+
+var indexPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// codeFromField creates a stable error code for a field, array indices are left out: provision.actor.0 + role -> provision.actor.role
+func codeFromField(prefix string, field string, property string, rule string) string {
+	var parts []string
+	for _, segment := range fieldSegments(field, property) {
+		if !indexPattern.MatchString(segment) {
+			parts = append(parts, segment)
+		}
+	}
+	return fmt.Sprintf("%s.%s-%s", prefix, strings.Join(parts, "."), rule)
+}
+
+func fieldSegments(field string, property string) []string {
+	var segments []string
+	if field != "" && field != "(root)" {
+		segments = strings.Split(field, ".")
+	}
+	if property != "" {
+		segments = append(segments, property)
+	}
+	return segments
+}
+
+func escapePointer(segment string) string {
+	return strings.Replace(strings.Replace(segment, "~", "~0", -1), "/", "~1", -1)
+}
+
+// ExtractionError is returned by the Consent extractors when a value is missing or can not be interpreted.
+// A consent that passed the json schema can still lack the fields the extractors need.
+type ExtractionError struct {
+	// Field is the "provision.period" style field that could not be extracted
+	Field string
+	// Rule that is broken: missing or invalid
+	Rule string
+	// Actual is the value that was found, if any
+	Actual string
+}
+
+// Error returns the field prefixed description
+func (e ExtractionError) Error() string {
+	if e.Rule == ruleInvalid {
+		return fmt.Sprintf("%s: value [%s] can not be extracted", e.Field, e.Actual)
+	}
+	return fmt.Sprintf("%s: value is missing", e.Field)
+}
+
+// rules of extraction errors
+const (
+	ruleMissing = "missing"
+	ruleInvalid = "invalid"
+)
+
+func missingValue(field string) error {
+	return ExtractionError{Field: field, Rule: ruleMissing}
+}
+
+func invalidValue(field string, actual string) error {
+	return ExtractionError{Field: field, Rule: ruleInvalid, Actual: actual}
+}
+
+// ErrorFrom converts an error to a ValidationError. Extraction errors become constraint errors pointing to the field, other errors are syntax errors.
+func ErrorFrom(err error) ValidationError {
+	var ee ExtractionError
+	if !errors.As(err, &ee) {
+		return SyntaxError(err)
+	}
+
+	return ValidationError{
+		Type:     TypeConstraint,
+		Code:     codeFromField("extract", ee.Field, "", ee.Rule),
+		Pointer:  pointerFromField(ee.Field, ""),
+		Message:  ee.Error(),
+		Actual:   ee.Actual,
+		Severity: SeverityError,
+	}
+}
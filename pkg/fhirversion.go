@@ -0,0 +1,118 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nuts-foundation/nuts-fhir-validation/schema"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// FHIR versions with a Consent schema, in the major.minor form of the fhirVersion mime type parameter
+const (
+	FHIRVersionSTU3 = "3.0"
+	FHIRVersionR4   = "4.0"
+	FHIRVersionR5   = "5.0"
+)
+
+// --version config flag, the FHIR version of records that do not specify one
+const ConfigFHIRVersion = "version"
+
+// default FHIR version
+const ConfigFHIRVersionDefault = FHIRVersionR4
+
+// releaseNames maps the FHIR release names to versions
+var releaseNames = map[string]string{
+	"STU3": FHIRVersionSTU3,
+	"R4":   FHIRVersionR4,
+	"R5":   FHIRVersionR5,
+}
+
+// consentSchemas holds the embedded Consent schemas of the FHIR versions other than R4.
+// The R4 schema is derived from the full FHIR schema, see Configure.
+var consentSchemas = map[string]string{
+	FHIRVersionSTU3: "consent.stu3.schema.json",
+	FHIRVersionR5:   "consent.r5.schema.json",
+}
+
+// ParseFHIRVersion returns the major.minor version for a release name (STU3, R4, R5) or version (4.0, 4.0.1).
+// An error is returned for versions without a Consent schema.
+func ParseFHIRVersion(value string) (string, error) {
+	v := strings.TrimSpace(value)
+	if version, ok := releaseNames[strings.ToUpper(v)]; ok {
+		return version, nil
+	}
+
+	if parts := strings.Split(v, "."); len(parts) >= 2 {
+		v = parts[0] + "." + parts[1]
+	}
+	switch v {
+	case FHIRVersionSTU3, FHIRVersionR4, FHIRVersionR5:
+		return v, nil
+	}
+	return "", fmt.Errorf("unsupported FHIR version %s, use %s (STU3), %s (R4) or %s (R5)", value, FHIRVersionSTU3, FHIRVersionR4, FHIRVersionR5)
+}
+
+// fhirVersion returns the parsed version, an empty version is the configured FHIR version
+func (ve *Validator) fhirVersion(value string) (string, error) {
+	if ve.current() == nil {
+		return "", ErrNotConfigured
+	}
+	if value == "" {
+		value = ve.Config.Version
+	}
+	return ParseFHIRVersion(value)
+}
+
+// FHIRVersions returns the FHIR versions the Validator has a schema for
+func (ve *Validator) FHIRVersions() []string {
+	if r := ve.current(); r != nil {
+		return r.fhirVersions()
+	}
+	return nil
+}
+
+func (r *rules) fhirVersions() []string {
+	var versions []string
+	for _, v := range []string{FHIRVersionSTU3, FHIRVersionR4, FHIRVersionR5} {
+		if r.schemas[v] != nil {
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+// loadConsentSchemas compiles the embedded Consent schemas of the FHIR versions other than R4, the schemas are written to the digest
+func loadConsentSchemas(schemas map[string]*gojsonschema.Schema, digest io.Writer) error {
+	for _, version := range []string{FHIRVersionSTU3, FHIRVersionR5} {
+		data, err := schema.Asset(consentSchemas[version])
+		if err != nil {
+			return err
+		}
+		digest.Write(data)
+		if schemas[version], err = gojsonschema.NewSchema(gojsonschema.NewBytesLoader(data)); err != nil {
+			return fmt.Errorf("unable to load Consent schema for FHIR %s: %w", version, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFHIRVersion(t *testing.T) {
+	for value, expected := range map[string]string{
+		"3.0":   FHIRVersionSTU3,
+		"3.0.1": FHIRVersionSTU3,
+		"stu3":  FHIRVersionSTU3,
+		"R4":    FHIRVersionR4,
+		"4.0.1": FHIRVersionR4,
+		"5.0":   FHIRVersionR5,
+		" R5 ":  FHIRVersionR5,
+	} {
+		version, err := ParseFHIRVersion(value)
+
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, version, value)
+	}
+
+	for _, value := range []string{"", "1.0.2", "4", "R6", "latest"} {
+		_, err := ParseFHIRVersion(value)
+
+		assert.Error(t, err, value)
+	}
+}
+
+func TestValidator_FHIRVersions(t *testing.T) {
+	assert.Equal(t, []string{FHIRVersionSTU3, FHIRVersionR4, FHIRVersionR5}, validationBackend().FHIRVersions())
+	assert.Empty(t, (&Validator{}).FHIRVersions())
+}
+
+func TestValidator_ValidateFHIRVersion(t *testing.T) {
+	client := validationBackend()
+	r4, _ := ioutil.ReadFile("../examples/observation_consent.json")
+	stu3, _ := ioutil.ReadFile("../examples/stu3_consent.json")
+	r5, _ := ioutil.ReadFile("../examples/r5_consent.json")
+
+	t.Run("records are valid for their own version only", func(t *testing.T) {
+		for version, data := range map[string][]byte{FHIRVersionSTU3: stu3, FHIRVersionR4: r4, FHIRVersionR5: r5} {
+			for v := range map[string][]byte{FHIRVersionSTU3: stu3, FHIRVersionR4: r4, FHIRVersionR5: r5} {
+				report, err := client.ValidateFHIRVersion(data, v)
+
+				assert.NoError(t, err)
+				assert.Equal(t, version == v, report.Valid(), "%s record validated as %s", version, v)
+				assert.Equal(t, v, report.FHIRVersion)
+			}
+		}
+	})
+
+	t.Run("R5 record is mapped onto R4 for the simplified consent", func(t *testing.T) {
+		report, err := client.ValidateFHIRVersion(r5, "R5")
+
+		assert.NoError(t, err)
+		assert.Equal(t, FHIRVersionR5, report.FHIRVersion)
+		if assert.NotNil(t, report.Consent) {
+			assert.Equal(t, Identifier("urn:oid:2.16.840.1.113883.2.4.6.3:999999990"), report.Consent.Subject)
+			assert.Equal(t, Identifier("urn:oid:2.16.840.1.113883.2.4.6.1:00000000"), report.Consent.Custodian)
+			assert.Equal(t, []Identifier{"urn:oid:2.16.840.1.113883.2.4.6.1:00000007"}, report.Consent.Actors)
+		}
+	})
+
+	t.Run("STU3 record is mapped onto R4 for the simplified consent", func(t *testing.T) {
+		report, err := client.ValidateFHIRVersion(stu3, "STU3")
+
+		assert.NoError(t, err)
+		assert.True(t, report.Valid())
+		assert.Equal(t, FHIRVersionSTU3, report.FHIRVersion)
+		if assert.NotNil(t, report.Consent) {
+			assert.Equal(t, Identifier("urn:oid:2.16.840.1.113883.2.4.6.3:999999990"), report.Consent.Subject)
+			assert.Equal(t, []Identifier{"urn:oid:2.16.840.1.113883.2.4.6.1:00000007"}, report.Consent.Actors)
+			assert.Equal(t, []string{"urn:oid:1.3.6.1.4.1.54851.1:MEDICAL"}, report.Consent.Resources)
+		}
+	})
+
+	t.Run("STU3 record is checked against the Nuts consent profile", func(t *testing.T) {
+		report, err := client.ValidateFHIRVersion([]byte(strings.Replace(string(stu3), `"dateTime": "2019-01-01",`, "", 1)), "STU3")
+
+		assert.NoError(t, err)
+		assert.False(t, report.Valid())
+		assert.Nil(t, report.Consent)
+		assert.Contains(t, errorCodes(report.Errors), "nuts.dateTime-required")
+		assert.NotContains(t, errorCodes(report.Errors), "nuts.verification-required")
+	})
+
+	t.Run("STU3 record uses the STU3 data types", func(t *testing.T) {
+		// Reference.type was added in R4
+		report, err := client.ValidateFHIRVersion([]byte(strings.Replace(string(stu3), `"patient": {`, `"patient": {"type": "Patient", `, 1)), "STU3")
+
+		assert.NoError(t, err)
+		assert.False(t, report.Valid())
+	})
+
+	t.Run("STU3 record without status is invalid", func(t *testing.T) {
+		report, err := client.ValidateFHIRVersion([]byte(strings.Replace(string(stu3), `"status": "active",`, "", 1)), "STU3")
+
+		assert.NoError(t, err)
+		assert.False(t, report.Valid())
+	})
+
+	t.Run("R5 record violating the node policy is invalid", func(t *testing.T) {
+		v := &Validator{}
+		v.Config.Policy.Classes = "urn:oid:1.3.6.1.4.1.54851.1:SOCIAL"
+		if err := v.Configure(); err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := v.ValidateFHIRVersion(r5, "R5")
+
+		assert.NoError(t, err)
+		assert.False(t, report.Valid())
+		assert.Nil(t, report.Consent)
+	})
+
+	t.Run("configured version is used by default", func(t *testing.T) {
+		v := &Validator{}
+		v.Config.Version = "R5"
+		if err := v.Configure(); err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := v.Validate(r5)
+
+		assert.NoError(t, err)
+		assert.True(t, report.Valid())
+		assert.Equal(t, FHIRVersionR5, v.Config.Version)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		_, err := client.ValidateFHIRVersion(r4, "6.0")
+
+		assert.Error(t, err)
+	})
+
+	t.Run("unsupported configured version", func(t *testing.T) {
+		v := &Validator{}
+		v.Config.Version = "6.0"
+
+		assert.Error(t, v.Configure())
+	})
+}
@@ -0,0 +1,207 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"encoding/json"
+)
+
+// STU3 policyRule uris of the FHIR ConsentPolicy code system
+const (
+	stu3PolicyOptIn  = "http://hl7.org/fhir/ConsentPolicy/opt-in"
+	stu3PolicyOptOut = "http://hl7.org/fhir/ConsentPolicy/opt-out"
+)
+
+// mapToR4 maps a STU3 or R5 Consent onto the R4 Consent model, so the Nuts consent profile, class registry, node policy and extractors can be applied.
+// Elements without R4 counterpart are left out, required Nuts elements that are missing in the record are then reported by the R4 stages.
+// The record is expected to have passed the schema of its FHIR version, R4 records are returned unchanged.
+func mapToR4(resource map[string]interface{}, fhirVersion string) map[string]interface{} {
+	switch fhirVersion {
+	case FHIRVersionSTU3:
+		return mapSTU3(resource)
+	case FHIRVersionR5:
+		return mapR5(resource)
+	}
+	return resource
+}
+
+// mapJSONToR4 maps the json of a STU3 or R5 Consent onto the R4 Consent model, see mapToR4
+func mapJSONToR4(data []byte, fhirVersion string) ([]byte, error) {
+	if fhirVersion == FHIRVersionR4 {
+		return data, nil
+	}
+	var resource map[string]interface{}
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mapToR4(resource, fhirVersion))
+}
+
+// mapSTU3 maps the STU3 elements: the elements that control access move into the root provision, except becomes the nested provisions
+// and the policyRule uri becomes a coding. An except without action is controlled by the actions of the Consent, they are copied into it.
+// STU3 has no verification, the STU3 rules of the Nuts consent profile do not require it.
+func mapSTU3(stu3 map[string]interface{}) map[string]interface{} {
+	r4 := copyElements(stu3, "resourceType", "id", "meta", "implicitRules", "language", "text", "contained", "extension", "modifierExtension",
+		"status", "category", "patient", "dateTime", "organization", "sourceAttachment", "sourceReference")
+	if identifier, ok := stu3["identifier"]; ok {
+		r4["identifier"] = []interface{}{identifier}
+	}
+	setElement(r4, "performer", stu3["consentingParty"])
+	if identifier, ok := stu3["sourceIdentifier"]; ok {
+		// R4 has no identifier as source, it becomes a reference by identifier
+		r4["sourceReference"] = map[string]interface{}{"identifier": identifier}
+	}
+
+	if uri, ok := stu3["policyRule"].(string); ok {
+		r4["policyRule"] = policyRuleFrom(map[string]string{stu3PolicyOptIn: policyOptIn, stu3PolicyOptOut: policyOptOut}[uri], uri)
+	}
+
+	provision := copyElements(stu3, "period", "actor", "action", "securityLabel", "purpose", "dataPeriod", "data")
+	var nested []interface{}
+	for _, except := range objects(stu3["except"]) {
+		p := copyElements(except, "type", "period", "actor", "action", "securityLabel", "purpose", "class", "dataPeriod", "data")
+		if _, ok := p["action"]; !ok {
+			setElement(p, "action", stu3["action"])
+		}
+		var codes []interface{}
+		for _, coding := range objects(except["code"]) {
+			// a Coding in STU3, a CodeableConcept in R4
+			codes = append(codes, map[string]interface{}{"coding": []interface{}{coding}})
+		}
+		setElement(p, "code", codes)
+		nested = append(nested, p)
+	}
+	setElement(provision, "provision", nested)
+	if len(provision) > 0 {
+		r4["provision"] = provision
+	}
+	return r4
+}
+
+// mapR5 maps the R5 elements: subject, date, controller and grantor are renamed, the decision becomes the policyRule and the type of the provisions,
+// the resourceType and documentType of a provision become its classes. The root provision holds the period of the Consent and the actors of the provisions.
+func mapR5(r5 map[string]interface{}) map[string]interface{} {
+	r4 := copyElements(r5, "resourceType", "id", "meta", "status", "category")
+	setElement(r4, "patient", r5["subject"])
+	setElement(r4, "dateTime", r5["date"])
+	setElement(r4, "organization", r5["controller"])
+	setElement(r4, "performer", r5["grantor"])
+	setElement(r4, "sourceAttachment", first(r5["sourceAttachment"]))
+	setElement(r4, "sourceReference", first(r5["sourceReference"]))
+
+	var verifications []interface{}
+	for _, v := range objects(r5["verification"]) {
+		verification := copyElements(v, "verified", "verifiedWith")
+		setElement(verification, "verificationDate", first(v["verificationDate"]))
+		verifications = append(verifications, verification)
+	}
+	setElement(r4, "verification", verifications)
+
+	decision, _ := r5["decision"].(string)
+	if decision == "" {
+		// the default decision of R5
+		decision = ProvisionPermit
+	}
+	r4["policyRule"] = policyRuleFrom(map[string]string{ProvisionPermit: policyOptIn, ProvisionDeny: policyOptOut}[decision], decision)
+
+	provisions := objects(r5["provision"])
+	if _, ok := r5["period"]; ok || len(provisions) > 0 {
+		root := copyElements(r5, "period")
+		var actors []interface{}
+		for _, p := range provisions {
+			for _, actor := range objects(p["actor"]) {
+				if !containsElement(actors, actor) {
+					actors = append(actors, actor)
+				}
+			}
+		}
+		setElement(root, "actor", actors)
+		setElement(root, "provision", mapR5Provisions(provisions, decision))
+		r4["provision"] = root
+	}
+	return r4
+}
+
+// mapR5Provisions maps the R5 provisions, the provisions of a level have the opposite type of their parent
+func mapR5Provisions(provisions []map[string]interface{}, kind string) []interface{} {
+	var result []interface{}
+	for _, p := range provisions {
+		provision := copyElements(p, "period", "actor", "action", "dataPeriod")
+		provision["type"] = kind
+
+		var classes []interface{}
+		for _, coding := range objects(p["resourceType"]) {
+			classes = append(classes, coding)
+		}
+		for _, coding := range objects(p["documentType"]) {
+			classes = append(classes, coding)
+		}
+		setElement(provision, "class", classes)
+
+		opposite := ProvisionDeny
+		if kind == ProvisionDeny {
+			opposite = ProvisionPermit
+		}
+		setElement(provision, "provision", mapR5Provisions(objects(p["provision"]), opposite))
+		result = append(result, provision)
+	}
+	return result
+}
+
+// policyRuleFrom returns the R4 policyRule for the OPTIN or OPTOUT code, other values are kept as text and rejected by the Nuts consent profile
+func policyRuleFrom(code string, value string) map[string]interface{} {
+	if code == "" {
+		return map[string]interface{}{"text": value}
+	}
+	return map[string]interface{}{"coding": []interface{}{map[string]interface{}{"system": PolicyRuleSystem, "code": code}}}
+}
+
+// copyElements returns a new object with the given elements of the source that are present
+func copyElements(source map[string]interface{}, elements ...string) map[string]interface{} {
+	target := map[string]interface{}{}
+	for _, e := range elements {
+		setElement(target, e, source[e])
+	}
+	return target
+}
+
+// setElement sets the element when the value is not empty
+func setElement(target map[string]interface{}, element string, value interface{}) {
+	if !isEmpty(value) {
+		target[element] = value
+	}
+}
+
+// first returns the first item of a list
+func first(value interface{}) interface{} {
+	if list, ok := value.([]interface{}); ok && len(list) > 0 {
+		return list[0]
+	}
+	return nil
+}
+
+func containsElement(list []interface{}, value interface{}) bool {
+	for _, v := range list {
+		if jsonString(v) == jsonString(value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thedevsaddam/gojsonq/v2"
+)
+
+func TestMapToR4(t *testing.T) {
+	read := func(file string) map[string]interface{} {
+		data, _ := ioutil.ReadFile(file)
+		var resource map[string]interface{}
+		_ = json.Unmarshal(data, &resource)
+		return resource
+	}
+
+	t.Run("R4 is returned unchanged", func(t *testing.T) {
+		r4 := read("../examples/observation_consent.json")
+
+		assert.Equal(t, r4, mapToR4(r4, FHIRVersionR4))
+	})
+
+	t.Run("STU3", func(t *testing.T) {
+		jsonq := gojsonq.New().FromInterface(mapToR4(read("../examples/stu3_consent.json"), FHIRVersionSTU3))
+
+		assert.Equal(t, policyOptIn, codeFrom(jsonq.Copy().Find("policyRule.coding"), PolicyRuleSystem))
+		assert.Equal(t, "2019-01-01", jsonq.Copy().Find("provision.period.start"))
+		assert.Len(t, objectsAt(jsonq, "provision.actor"), 1)
+		assert.Equal(t, ProvisionPermit, jsonq.Copy().Find("provision.provision.[0].type"))
+		assert.Equal(t, "MEDICAL", jsonq.Copy().Find("provision.provision.[0].class.[0].code"))
+		assert.Nil(t, jsonq.Copy().Find("except"))
+		assert.Equal(t, "access", codeFrom(jsonq.Copy().Find("provision.provision.[0].action.[0].coding"), ConsentActionSystem))
+		assert.Equal(t, "00000000", jsonq.Copy().Find("performer.[0].identifier.value"))
+		assert.Len(t, objectsAt(jsonq, "identifier"), 1)
+		assert.Empty(t, validateNutsProfileVersion(jsonq, FHIRVersionSTU3))
+	})
+
+	t.Run("STU3 except keeps its own action and code", func(t *testing.T) {
+		stu3 := read("../examples/stu3_consent.json")
+		except := stu3["except"].([]interface{})[0].(map[string]interface{})
+		except["action"] = []interface{}{map[string]interface{}{"coding": []interface{}{map[string]interface{}{"system": ConsentActionSystem, "code": "correct"}}}}
+		except["code"] = []interface{}{map[string]interface{}{"system": "http://loinc.org", "code": "8310-5"}}
+
+		jsonq := gojsonq.New().FromInterface(mapToR4(stu3, FHIRVersionSTU3))
+
+		assert.Equal(t, "correct", codeFrom(jsonq.Copy().Find("provision.provision.[0].action.[0].coding"), ConsentActionSystem))
+		assert.Equal(t, "8310-5", jsonq.Copy().Find("provision.provision.[0].code.[0].coding.[0].code"))
+	})
+
+	t.Run("STU3 with another policyRule", func(t *testing.T) {
+		stu3 := read("../examples/stu3_consent.json")
+		stu3["policyRule"] = "http://hl7.org/fhir/ConsentPolicy/hipaa-auth"
+
+		r4 := mapToR4(stu3, FHIRVersionSTU3)
+
+		assert.Equal(t, map[string]interface{}{"text": "http://hl7.org/fhir/ConsentPolicy/hipaa-auth"}, r4["policyRule"])
+	})
+
+	t.Run("R5", func(t *testing.T) {
+		jsonq := gojsonq.New().FromInterface(mapToR4(read("../examples/r5_consent.json"), FHIRVersionR5))
+
+		assert.Equal(t, "999999990", jsonq.Copy().Find("patient.identifier.value"))
+		assert.Equal(t, "2023-01-01", jsonq.Copy().Find("dateTime"))
+		assert.Equal(t, "consent", jsonq.Copy().Find("sourceAttachment.title"))
+		assert.Equal(t, policyOptIn, codeFrom(jsonq.Copy().Find("policyRule.coding"), PolicyRuleSystem))
+		assert.Len(t, objectsAt(jsonq, "provision.actor"), 1)
+		assert.Equal(t, ProvisionPermit, jsonq.Copy().Find("provision.provision.[0].type"))
+		assert.Len(t, objectsAt(jsonq, "provision.provision.[0].class"), 2)
+		assert.Empty(t, validateNutsProfile(jsonq))
+	})
+
+	t.Run("R5 deny decision maps to OPTOUT with deny provisions", func(t *testing.T) {
+		r5 := read("../examples/r5_consent.json")
+		r5["decision"] = ProvisionDeny
+
+		jsonq := gojsonq.New().FromInterface(mapToR4(r5, FHIRVersionR5))
+
+		assert.Equal(t, policyOptOut, codeFrom(jsonq.Copy().Find("policyRule.coding"), PolicyRuleSystem))
+		assert.Equal(t, ProvisionDeny, jsonq.Copy().Find("provision.provision.[0].type"))
+	})
+}
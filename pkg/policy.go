@@ -0,0 +1,212 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// --policy.custodians config flag
+const ConfigPolicyCustodians = "policy.custodians"
+
+// --policy.classes config flag
+const ConfigPolicyClasses = "policy.classes"
+
+// --policy.contenttypes config flag
+const ConfigPolicyContentTypes = "policy.contenttypes"
+
+// --policy.maxperiod config flag
+const ConfigPolicyMaxPeriod = "policy.maxperiod"
+
+// --policy.file config flag
+const ConfigPolicyFile = "policy.file"
+
+// PolicyConfig holds the node policy settings. Lists are comma separated, an empty list or 0 means no restriction.
+type PolicyConfig struct {
+	// Custodians lists the custodian identifiers (urn:oid:...:value) this node accepts records for
+	Custodians string
+	// Classes lists the consent classes this node accepts in provisions
+	Classes string
+	// Contenttypes lists the sourceAttachment content types this node accepts as proof
+	Contenttypes string
+	// Maxperiod is the maximum length of the provision period in days
+	Maxperiod int
+	// File is a yaml file holding the policy, it replaces the other settings and is read again on every Reload
+	File string
+}
+
+// policyFile is the format of the policy file, the keys are the policy settings with yaml lists instead of comma separated lists
+type policyFile struct {
+	Custodians   []string `yaml:"custodians"`
+	Classes      []string `yaml:"classes"`
+	Contenttypes []string `yaml:"contenttypes"`
+	Maxperiod    int      `yaml:"maxperiod"`
+}
+
+// policy is the parsed form of the PolicyConfig
+type policy struct {
+	custodians   []string
+	classes      []string
+	contentTypes []string
+	maxPeriod    time.Duration
+}
+
+// loadPolicy parses the policy settings or, when configured, reads the policy file. The settings or file are written to the digest.
+func loadPolicy(config PolicyConfig, digest io.Writer) (policy, error) {
+	if config.File == "" {
+		data, _ := json.Marshal(config)
+		digest.Write(data)
+		return newPolicy(config)
+	}
+
+	data, err := ioutil.ReadFile(config.File)
+	if err != nil {
+		return policy{}, err
+	}
+	digest.Write(data)
+
+	var file policyFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return policy{}, fmt.Errorf("unable to load policy file %s: %w", config.File, err)
+	}
+	return newPolicy(PolicyConfig{
+		Custodians:   strings.Join(file.Custodians, ","),
+		Classes:      strings.Join(file.Classes, ","),
+		Contenttypes: strings.Join(file.Contenttypes, ","),
+		Maxperiod:    file.Maxperiod,
+	})
+}
+
+func newPolicy(config PolicyConfig) (policy, error) {
+	if config.Maxperiod < 0 {
+		return policy{}, fmt.Errorf("invalid %s: %d, must be 0 or more days", ConfigPolicyMaxPeriod, config.Maxperiod)
+	}
+
+	return policy{
+		custodians:   splitList(config.Custodians),
+		classes:      splitList(config.Classes),
+		contentTypes: splitList(config.Contenttypes),
+		maxPeriod:    time.Duration(config.Maxperiod) * 24 * time.Hour,
+	}, nil
+}
+
+// ValidateAgainstPolicy checks the consent record against the settings of this node.
+// The record is expected to have passed ValidateAgainstSchema.
+func (ve *Validator) ValidateAgainstPolicy(json []byte) ([]ValidationError, error) {
+	r := ve.current()
+	if r == nil {
+		return nil, ErrNotConfigured
+	}
+	return r.validatePolicy(json)
+}
+
+func (r *rules) validatePolicy(json []byte) ([]ValidationError, error) {
+	consent, err := ParseConsent(json)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.policy.validate(consent), nil
+}
+
+// validate checks the consent against the policy, values that are needed for a configured check but can not be extracted are reported as well
+func (p policy) validate(consent *Consent) []ValidationError {
+	var errs []ValidationError
+
+	if len(p.custodians) > 0 {
+		if custodian, err := consent.Custodian(); err != nil {
+			errs = append(errs, ErrorFrom(err))
+		} else if !allowed(p.custodians, custodian) {
+			errs = append(errs, policyError("organization.0.identifier", "custodian-not-allowed", custodian,
+				"custodian %s is not allowed by this node", custodian))
+		}
+	}
+
+	if len(p.classes) > 0 {
+		classes, err := consent.DataClasses()
+		if err != nil {
+			errs = append(errs, ErrorFrom(err))
+		}
+		for _, class := range classes {
+			if !allowed(p.classes, class) {
+				errs = append(errs, policyError("provision.provision", "class-not-allowed", class,
+					"class %s is not allowed by this node", class))
+			}
+		}
+	}
+
+	contentType := ""
+	if consent.SourceAttachment != nil {
+		contentType = consent.SourceAttachment.ContentType
+	}
+	if !allowed(p.contentTypes, contentType) {
+		errs = append(errs, policyError("sourceAttachment.contentType", "content-type-not-allowed", contentType,
+			"content type %s is not allowed by this node", contentType))
+	}
+
+	if p.maxPeriod > 0 {
+		days := int(p.maxPeriod / (24 * time.Hour))
+		period, err := consent.Period()
+		if err != nil {
+			errs = append(errs, ErrorFrom(err))
+		} else if period[1] == nil {
+			errs = append(errs, policyError("provision.period", "period-too-long", "",
+				"period without end exceeds the maximum of %d days", days))
+		} else if length := period[1].Sub(*period[0]); length > p.maxPeriod {
+			errs = append(errs, policyError("provision.period", "period-too-long", length.String(),
+				"period exceeds the maximum of %d days", days))
+		}
+	}
+
+	return errs
+}
+
+func policyError(field string, code string, actual string, format string, a ...interface{}) ValidationError {
+	return ValidationError{
+		Type:     TypePolicy,
+		Code:     "policy." + code,
+		Pointer:  pointerFromField(field, ""),
+		Message:  fmt.Sprintf("%s: %s", field, fmt.Sprintf(format, a...)),
+		Actual:   actual,
+		Severity: SeverityError,
+	}
+}
+
+// allowed returns true when the list is empty (no restriction) or contains the value
+func allowed(list []string, value string) bool {
+	return len(list) == 0 || contains(list, value)
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
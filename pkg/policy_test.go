@@ -0,0 +1,108 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidator_ValidateAgainstPolicy(t *testing.T) {
+	bytes, _ := ioutil.ReadFile("../examples/observation_consent.json")
+	unlimited, _ := ioutil.ReadFile("../examples/observation_consent_unl.json")
+
+	validatorWith := func(config PolicyConfig) *Validator {
+		v := &Validator{}
+		v.Config.Policy = config
+		if err := v.Configure(); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	t.Run("empty policy allows all", func(t *testing.T) {
+		errs, err := validatorWith(PolicyConfig{}).ValidateAgainstPolicy(bytes)
+
+		assert.NoError(t, err)
+		assert.Empty(t, errs)
+	})
+
+	t.Run("allowed custodian and classes", func(t *testing.T) {
+		errs, _ := validatorWith(PolicyConfig{
+			Custodians:   "urn:oid:2.16.840.1.113883.2.4.6.1:00000000",
+			Classes:      "http://hl7.org/fhir/resource-types#Observation, urn:oid:1.3.6.1.4.1.54851.1:MEDICAL",
+			Contenttypes: "application/pdf",
+		}).ValidateAgainstPolicy(bytes)
+
+		assert.Empty(t, errs)
+	})
+
+	t.Run("custodian not allowed", func(t *testing.T) {
+		errs, _ := validatorWith(PolicyConfig{Custodians: "urn:oid:2.16.840.1.113883.2.4.6.1:00000001"}).ValidateAgainstPolicy(bytes)
+
+		assert.Equal(t, []ValidationError{{
+			Type:     TypePolicy,
+			Code:     "policy.custodian-not-allowed",
+			Pointer:  "/organization/0/identifier",
+			Message:  "organization.0.identifier: custodian urn:oid:2.16.840.1.113883.2.4.6.1:00000000 is not allowed by this node",
+			Actual:   "urn:oid:2.16.840.1.113883.2.4.6.1:00000000",
+			Severity: SeverityError,
+		}}, errs)
+	})
+
+	t.Run("class not allowed", func(t *testing.T) {
+		errs, _ := validatorWith(PolicyConfig{Classes: "urn:oid:1.3.6.1.4.1.54851.1:MEDICAL"}).ValidateAgainstPolicy(bytes)
+
+		assert.Equal(t, []string{"provision.provision: class http://hl7.org/fhir/resource-types#Observation is not allowed by this node"}, messages(errs))
+	})
+
+	t.Run("content type not allowed", func(t *testing.T) {
+		errs, _ := validatorWith(PolicyConfig{Contenttypes: "application/json+irma"}).ValidateAgainstPolicy(bytes)
+
+		assert.Equal(t, []string{"sourceAttachment.contentType: content type application/pdf is not allowed by this node"}, messages(errs))
+	})
+
+	t.Run("period within maximum", func(t *testing.T) {
+		errs, _ := validatorWith(PolicyConfig{Maxperiod: 1}).ValidateAgainstPolicy(bytes)
+
+		assert.Empty(t, errs)
+	})
+
+	t.Run("period without end exceeds maximum", func(t *testing.T) {
+		errs, _ := validatorWith(PolicyConfig{Maxperiod: 365}).ValidateAgainstPolicy(unlimited)
+
+		assert.Equal(t, []string{"provision.period: period without end exceeds the maximum of 365 days"}, messages(errs))
+	})
+
+	t.Run("invalid json returns error", func(t *testing.T) {
+		_, err := validatorWith(PolicyConfig{}).ValidateAgainstPolicy([]byte("{"))
+
+		assert.Error(t, err)
+	})
+
+	t.Run("negative maximum period is a config error", func(t *testing.T) {
+		v := &Validator{}
+		v.Config.Policy.Maxperiod = -1
+
+		assert.Error(t, v.Configure())
+	})
+}
@@ -0,0 +1,295 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thedevsaddam/gojsonq/v2"
+)
+
+// NutsPatientSystem is the only identifier system allowed for the patient of a Nuts consent record (BSN)
+const NutsPatientSystem = "urn:oid:2.16.840.1.113883.2.4.6.3"
+
+// PolicyRuleSystem is the code system used for the OPTIN/OPTOUT policyRule
+const PolicyRuleSystem = "http://terminology.hl7.org/CodeSystem/v3-ActCode"
+
+// ConsentActionSystem is the code system used for provision actions
+const ConsentActionSystem = "http://terminology.hl7.org/CodeSystem/consentaction"
+
+// ParticipationTypeSystem is the code system used for the role of provision actors
+const ParticipationTypeSystem = "http://terminology.hl7.org/CodeSystem/v3-ParticipationType"
+
+const (
+	policyOptIn   = "OPTIN"
+	policyOptOut  = "OPTOUT"
+	actorRole     = "PRCP"
+	nutsOIDPrefix = "urn:oid:"
+)
+
+var allowedActions = []string{"access", "correct", "disclose"}
+
+// profileErrors collects the violations of the Nuts consent profile. Messages use the same "field: description" format as the json schema errors.
+type profileErrors []ValidationError
+
+// add registers a violation for the given field or, when property is given, for the property of the field
+func (pe *profileErrors) add(field string, property string, rule string, format string, a ...interface{}) *ValidationError {
+	*pe = append(*pe, ValidationError{
+		Type:     TypeConstraint,
+		Code:     codeFromField("nuts", field, property, rule),
+		Pointer:  pointerFromField(field, property),
+		Message:  fmt.Sprintf("%s: %s", field, fmt.Sprintf(format, a...)),
+		Severity: SeverityError,
+	})
+	return &(*pe)[len(*pe)-1]
+}
+
+// invalid registers a violation for a field with an unexpected value
+func (pe *profileErrors) invalid(field string, expected string, actual string, format string, a ...interface{}) {
+	e := pe.add(field, "", "invalid", format, a...)
+	e.Expected = expected
+	e.Actual = actual
+}
+
+func (pe *profileErrors) required(parent string, property string, value interface{}) bool {
+	if isEmpty(value) {
+		pe.add(parent, property, "required", "%s is required", property)
+		return false
+	}
+	return true
+}
+
+func (pe *profileErrors) forbidden(parent string, property string, value map[string]interface{}) {
+	if v, ok := value[property]; ok {
+		pe.add(parent, property, "forbidden", "Additional property %s is not allowed", property).Actual = fmt.Sprint(v)
+	}
+}
+
+// validateNutsProfile checks the additional rules a R4 Consent must follow to be usable by the Nuts components.
+// The rules are described in docs/pages/technical/fhir-rules.rst. Documents that are not a Consent are skipped,
+// the json schema already reports on those.
+func validateNutsProfile(jsonq *gojsonq.JSONQ) []ValidationError {
+	return validateNutsProfileVersion(jsonq, FHIRVersionR4)
+}
+
+// validateNutsProfileVersion checks the rules of the Nuts consent profile for a Consent of the given FHIR version that is mapped onto R4.
+// STU3 has no verification element, the STU3 rules do not require it.
+func validateNutsProfileVersion(jsonq *gojsonq.JSONQ, fhirVersion string) []ValidationError {
+	if jsonq.Copy().Find("resourceType") != "Consent" {
+		return nil
+	}
+
+	var errs profileErrors
+
+	if errs.required("(root)", "meta", jsonq.Copy().Find("meta")) {
+		errs.required("meta", "versionId", jsonq.Copy().Find("meta.versionId"))
+		errs.required("meta", "lastUpdated", jsonq.Copy().Find("meta.lastUpdated"))
+	}
+
+	patient, _ := jsonq.Copy().Find("patient").(map[string]interface{})
+	if errs.required("(root)", "patient", patient) {
+		errs.forbidden("patient", "display", patient)
+		if errs.required("patient", "identifier", patient["identifier"]) {
+			identifier, _ := patient["identifier"].(map[string]interface{})
+			if system, _ := identifier["system"].(string); system != NutsPatientSystem {
+				errs.invalid("patient.identifier.system", NutsPatientSystem, system, "system must be %s", NutsPatientSystem)
+			}
+			errs.required("patient.identifier", "value", identifier["value"])
+		}
+	}
+
+	errs.required("(root)", "dateTime", jsonq.Copy().Find("dateTime"))
+
+	for i, performer := range objectsAt(jsonq, "performer") {
+		field := fmt.Sprintf("performer.%d", i)
+		errs.forbidden(field, "display", performer)
+		errs.nutsIdentifier(field, performer["identifier"])
+	}
+
+	organizations := objectsAt(jsonq, "organization")
+	if errs.required("(root)", "organization", organizations) {
+		for i, organization := range organizations {
+			errs.nutsIdentifier(fmt.Sprintf("organization.%d", i), organization["identifier"])
+		}
+	}
+
+	if jsonq.Copy().Find("sourceReference") != nil {
+		errs.add("(root)", "sourceReference", "forbidden", "source must be a sourceAttachment")
+	} else {
+		errs.required("(root)", "sourceAttachment", jsonq.Copy().Find("sourceAttachment"))
+	}
+
+	if fhirVersion != FHIRVersionSTU3 {
+		errs.required("(root)", "verification", objectsAt(jsonq, "verification"))
+	}
+
+	policy := ""
+	if errs.required("(root)", "policyRule", jsonq.Copy().Find("policyRule")) {
+		policy = codeFrom(jsonq.Copy().Find("policyRule.coding"), PolicyRuleSystem)
+		if policy != policyOptIn && policy != policyOptOut {
+			errs.invalid("policyRule.coding", policyOptIn+"|"+policyOptOut, policy, "coding must contain %s or %s from %s", policyOptIn, policyOptOut, PolicyRuleSystem)
+		}
+	}
+
+	provision, _ := jsonq.Copy().Find("provision").(map[string]interface{})
+	if errs.required("(root)", "provision", provision) {
+		errs.provision(provision, policy == policyOptIn)
+	}
+
+	return errs
+}
+
+// provision checks the top level provision and its nested provisions
+func (pe *profileErrors) provision(provision map[string]interface{}, optIn bool) {
+	actors := objects(provision["actor"])
+	if pe.required("provision", "actor", actors) {
+		pe.actors("provision", actors)
+	}
+
+	period, _ := provision["period"].(map[string]interface{})
+	if pe.required("provision", "period", period) {
+		pe.required("provision.period", "start", period["start"])
+	}
+
+	provisions := objects(provision["provision"])
+	if optIn {
+		pe.required("provision", "provision", provisions)
+	}
+	pe.nestedProvisions("provision", provisions)
+}
+
+// nestedProvisions checks the nested provisions recursively, a permit requires actions and classes, a deny only classes
+func (pe *profileErrors) nestedProvisions(parent string, provisions []map[string]interface{}) {
+	for i, p := range provisions {
+		field := fmt.Sprintf("%s.provision.%d", parent, i)
+		provisionType, _ := p["type"].(string)
+		if provisionType != ProvisionPermit && provisionType != ProvisionDeny {
+			pe.invalid(field+".type", ProvisionPermit+"|"+ProvisionDeny, provisionType, "type must be %s or %s", ProvisionPermit, ProvisionDeny)
+		}
+		if actors := objects(p["actor"]); len(actors) > 0 {
+			pe.actors(field, actors)
+		}
+		if period, ok := p["period"].(map[string]interface{}); ok {
+			pe.required(field+".period", "start", period["start"])
+		}
+		actions := objects(p["action"])
+		if provisionType != ProvisionPermit || pe.required(field, "action", actions) {
+			for j, action := range actions {
+				code := codeFrom(action["coding"], ConsentActionSystem)
+				if !contains(allowedActions, code) {
+					pe.invalid(fmt.Sprintf("%s.action.%d", field, j), strings.Join(allowedActions, "|"), code, "action must be one of %s from %s", strings.Join(allowedActions, ", "), ConsentActionSystem)
+				}
+			}
+		}
+		pe.required(field, "class", p["class"])
+		pe.nestedProvisions(field, objects(p["provision"]))
+	}
+}
+
+// actors checks the role and identifier of the actors of a provision
+func (pe *profileErrors) actors(parent string, actors []map[string]interface{}) {
+	for i, actor := range actors {
+		field := fmt.Sprintf("%s.actor.%d", parent, i)
+		if role := codeFrom(nested(actor, "role", "coding"), ParticipationTypeSystem); role != actorRole {
+			pe.invalid(field+".role", actorRole, role, "role must be %s", actorRole)
+		}
+		reference, _ := actor["reference"].(map[string]interface{})
+		if pe.required(field, "reference", reference) {
+			pe.nutsIdentifier(field+".reference", reference["identifier"])
+		}
+	}
+}
+
+// nutsIdentifier checks if the identifier is present and uses an urn:oid system
+func (pe *profileErrors) nutsIdentifier(field string, value interface{}) {
+	if !pe.required(field, "identifier", value) {
+		return
+	}
+	identifier, _ := value.(map[string]interface{})
+	if system, _ := identifier["system"].(string); !strings.HasPrefix(system, nutsOIDPrefix) {
+		pe.invalid(field+".identifier.system", nutsOIDPrefix+"...", system, "system must be a Nuts identifier system (%s...)", nutsOIDPrefix)
+	}
+	pe.required(field+".identifier", "value", identifier["value"])
+}
+
+// objectsAt returns the list of objects at the given path, non-objects are skipped
+func objectsAt(jsonq *gojsonq.JSONQ, path string) []map[string]interface{} {
+	return objects(jsonq.Copy().Find(path))
+}
+
+func objects(value interface{}) []map[string]interface{} {
+	list, _ := value.([]interface{})
+	var result []map[string]interface{}
+	for _, v := range list {
+		if m, ok := v.(map[string]interface{}); ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func nested(value map[string]interface{}, path ...string) interface{} {
+	var current interface{} = value
+	for _, p := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[p]
+	}
+	return current
+}
+
+// codeFrom returns the first code in a list of codings with the given system
+func codeFrom(codings interface{}, system string) string {
+	for _, coding := range objects(codings) {
+		if coding["system"] == system {
+			code, _ := coding["code"].(string)
+			return code
+		}
+	}
+	return ""
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case []map[string]interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return v == nil
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, l := range list {
+		if l == value {
+			return true
+		}
+	}
+	return false
+}
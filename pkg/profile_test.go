@@ -0,0 +1,131 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/thedevsaddam/gojsonq/v2"
+)
+
+func TestValidateNutsProfile(t *testing.T) {
+	bytes, _ := ioutil.ReadFile("../examples/observation_consent.json")
+	valid := string(bytes)
+
+	t.Run("valid consent has no errors", func(t *testing.T) {
+		assert.Empty(t, validateNutsProfile(gojsonq.New().FromString(valid)))
+	})
+
+	t.Run("non consent is skipped", func(t *testing.T) {
+		assert.Empty(t, validateNutsProfile(gojsonq.New().FromString("{}")))
+	})
+
+	t.Run("patient display is not allowed", func(t *testing.T) {
+		json := strings.Replace(valid, `"value": "999999990"
+    }
+  },`, `"value": "999999990"
+    },
+    "display": "P. Patient"
+  },`, 1)
+
+		errs := validateNutsProfile(gojsonq.New().FromString(json))
+
+		assert.Equal(t, []ValidationError{{
+			Type:     TypeConstraint,
+			Code:     "nuts.patient.display-forbidden",
+			Pointer:  "/patient/display",
+			Message:  "patient: Additional property display is not allowed",
+			Actual:   "P. Patient",
+			Severity: SeverityError,
+		}}, errs)
+	})
+
+	t.Run("patient must use BSN system", func(t *testing.T) {
+		json := strings.Replace(valid, `"system": "urn:oid:2.16.840.1.113883.2.4.6.3",
+      "value": "999999990"
+    }
+  },`, `"system": "http://fhir.nl/fhir/NamingSystem/bsn",
+      "value": "999999990"
+    }
+  },`, 1)
+
+		errs := validateNutsProfile(gojsonq.New().FromString(json))
+
+		assert.Equal(t, []string{"patient.identifier.system: system must be urn:oid:2.16.840.1.113883.2.4.6.3"}, messages(errs))
+		assert.Equal(t, "nuts.patient.identifier.system-invalid", errs[0].Code)
+		assert.Equal(t, NutsPatientSystem, errs[0].Expected)
+		assert.Equal(t, "http://fhir.nl/fhir/NamingSystem/bsn", errs[0].Actual)
+	})
+
+	t.Run("missing verification", func(t *testing.T) {
+		json := strings.Replace(valid, `"verification"`, `"_verification"`, 1)
+
+		errs := validateNutsProfile(gojsonq.New().FromString(json))
+
+		assert.Equal(t, []string{"(root): verification is required"}, messages(errs))
+		assert.Equal(t, "nuts.verification-required", errs[0].Code)
+		assert.Equal(t, "/verification", errs[0].Pointer)
+	})
+
+	t.Run("provision action must be access, correct or disclose", func(t *testing.T) {
+		json := strings.Replace(valid, `"code": "access"`, `"code": "use"`, 1)
+
+		errs := validateNutsProfile(gojsonq.New().FromString(json))
+
+		assert.Len(t, errs, 1)
+		assert.True(t, strings.HasPrefix(errs[0].Message, "provision.provision.0.action.0: action must be one of"))
+		assert.Equal(t, "nuts.provision.provision.action-invalid", errs[0].Code)
+		assert.Equal(t, "/provision/provision/0/action/0", errs[0].Pointer)
+		assert.Equal(t, "use", errs[0].Actual)
+	})
+
+	t.Run("nested deny provision is allowed", func(t *testing.T) {
+		json := strings.Replace(valid, `"type": "permit"`, `"type": "deny"`, 1)
+
+		assert.Empty(t, validateNutsProfile(gojsonq.New().FromString(json)))
+	})
+
+	t.Run("nested provision type must be permit or deny", func(t *testing.T) {
+		json := strings.Replace(valid, `"type": "permit"`, `"type": "maybe"`, 1)
+
+		errs := validateNutsProfile(gojsonq.New().FromString(json))
+
+		assert.Equal(t, []string{"provision.provision.0.type: type must be permit or deny"}, messages(errs))
+	})
+}
+
+func messages(errs []ValidationError) []string {
+	var result []string
+	for _, e := range errs {
+		result = append(result, e.Message)
+	}
+	return result
+}
+
+func errorCodes(errs []ValidationError) []string {
+	var result []string
+	for _, e := range errs {
+		result = append(result, e.Code)
+	}
+	return result
+}
@@ -0,0 +1,226 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nuts-foundation/nuts-fhir-validation/schema"
+)
+
+// --profiles config flag
+const ConfigProfiles = "profiles"
+
+// NutsConsentProfile is the canonical url of the nested Nuts consent StructureDefinition
+const NutsConsentProfile = "http://nuts.nl/fhir/StructureDefinition/nuts-consent"
+
+// ErrProfileNotApplicable is returned by ValidateAgainstProfile when the profile is not loaded or is defined for another resource type or FHIR version
+var ErrProfileNotApplicable = errors.New("profile can not be applied")
+
+// nutsProfileAsset is the nested Bundle with the Nuts consent StructureDefinition and its ValueSets
+const nutsProfileAsset = "profiles/nuts-consent.json"
+
+// profiles holds the loaded StructureDefinitions by url and the codes of the loaded ValueSets by url
+type profiles struct {
+	byURL     map[string]*Profile
+	valueSets map[string]codes
+}
+
+// loadProfiles loads the nested Nuts consent profile and the StructureDefinitions and ValueSets from the comma separated list of files and directories.
+// Directories are searched recursively for .json files, a file holds a single resource or a Bundle of resources.
+// The content of all loaded files is written to the digest.
+func loadProfiles(paths string, digest io.Writer) (profiles, error) {
+	p := profiles{byURL: map[string]*Profile{}, valueSets: map[string]codes{}}
+
+	data, err := schema.Asset(nutsProfileAsset)
+	if err != nil {
+		return p, err
+	}
+	digest.Write(data)
+	if err := p.load(data); err != nil {
+		return p, fmt.Errorf("%s: %w", nutsProfileAsset, err)
+	}
+
+	for _, path := range splitList(paths) {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || (file != path && !strings.EqualFold(filepath.Ext(file), ".json")) {
+				return nil
+			}
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			digest.Write(data)
+			if err := p.load(data); err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return p, fmt.Errorf("unable to load profiles: %w", err)
+		}
+	}
+
+	return p, nil
+}
+
+// load adds a StructureDefinition, ValueSet or the entries of a Bundle
+func (p profiles) load(data []byte) error {
+	var resource struct {
+		ResourceType string `json:"resourceType"`
+		Entry        []struct {
+			Resource json.RawMessage `json:"resource"`
+		} `json:"entry"`
+	}
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return err
+	}
+
+	switch resource.ResourceType {
+	case "Bundle":
+		for _, e := range resource.Entry {
+			if err := p.load(e.Resource); err != nil {
+				return err
+			}
+		}
+	case "StructureDefinition":
+		profile, err := ParseStructureDefinition(data)
+		if err != nil {
+			return err
+		}
+		p.byURL[profile.URL] = profile
+	case "ValueSet":
+		var vs ValueSet
+		if err := json.Unmarshal(data, &vs); err != nil {
+			return err
+		}
+		if vs.URL == "" {
+			return fmt.Errorf("ValueSet: url is required")
+		}
+		p.valueSets[vs.URL] = vs.codes()
+	default:
+		return fmt.Errorf("unsupported resource type %s, expected StructureDefinition, ValueSet or Bundle", resource.ResourceType)
+	}
+	return nil
+}
+
+// validate checks the resource against the profiles in meta.profile.
+// Profiles that are not loaded or are defined for another resource type or FHIR version are reported as error.
+func (p profiles) validate(resource map[string]interface{}, fhirVersion string) []ValidationError {
+	resourceType, _ := resource["resourceType"].(string)
+	list, _ := nested(resource, "meta", "profile").([]interface{})
+
+	var errs []ValidationError
+	for i, v := range list {
+		canonical, _ := v.(string)
+		profile, rule, err := p.find(canonical, resourceType, fhirVersion)
+		if err != nil {
+			errs = append(errs, profileError(fmt.Sprintf("meta.profile.%d", i), rule, canonical, "%s", err.Error()))
+			continue
+		}
+		errs = append(errs, profile.validate(resource, p.valueSets)...)
+	}
+	return errs
+}
+
+// find returns the loaded profile with the canonical url, a version after | is ignored.
+// When the profile is not loaded or can not be applied to the resource type and FHIR version, the rule and an error describing it are returned.
+func (p profiles) find(canonical string, resourceType string, fhirVersion string) (*Profile, string, error) {
+	profile, ok := p.byURL[strings.SplitN(canonical, "|", 2)[0]]
+	switch {
+	case !ok:
+		return nil, "unknown", fmt.Errorf("profile %s is not known by this node", canonical)
+	case profile.Type != resourceType:
+		return nil, "type-mismatch", fmt.Errorf("profile %s is defined for %s", canonical, profile.Type)
+	case profile.FHIRVersion != "" && profile.FHIRVersion != fhirVersion:
+		return nil, "version-mismatch", fmt.Errorf("profile %s is defined for FHIR %s", canonical, profile.FHIRVersion)
+	}
+	return profile, "", nil
+}
+
+// ValidateAgainstProfile validates the consent record against the loaded StructureDefinition with the canonical url, eg: the profile parameter of the FHIR $validate operation.
+// A profile that is also in the meta.profile of the record is already applied by ValidateFHIRVersion, no errors are returned for it.
+// An error wrapping ErrProfileNotApplicable is returned when the profile is not loaded or is defined for another resource type or FHIR version.
+// The record is expected to have passed ValidateAgainstSchema.
+func (ve *Validator) ValidateAgainstProfile(data []byte, fhirVersion string, canonical string) ([]ValidationError, error) {
+	fhirVersion, err := ve.fhirVersion(fhirVersion)
+	if err != nil {
+		return nil, err
+	}
+	p := ve.current().profiles
+
+	var resource map[string]interface{}
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return nil, err
+	}
+
+	resourceType, _ := resource["resourceType"].(string)
+	profile, _, err := p.find(canonical, resourceType, fhirVersion)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrProfileNotApplicable, err.Error())
+	}
+
+	list, _ := nested(resource, "meta", "profile").([]interface{})
+	for _, v := range list {
+		if listed, _ := v.(string); strings.SplitN(listed, "|", 2)[0] == profile.URL {
+			return nil, nil
+		}
+	}
+	return profile.validate(resource, p.valueSets), nil
+}
+
+func profileError(field string, rule string, actual string, format string, a ...interface{}) ValidationError {
+	return ValidationError{
+		Type:     TypeConstraint,
+		Code:     codeFromField("profile", "meta.profile", "", rule),
+		Pointer:  pointerFromField(field, ""),
+		Message:  fmt.Sprintf("%s: %s", field, fmt.Sprintf(format, a...)),
+		Actual:   actual,
+		Severity: SeverityError,
+	}
+}
+
+// Profiles returns the urls of the loaded StructureDefinitions
+func (ve *Validator) Profiles() []string {
+	if r := ve.current(); r != nil {
+		return r.profiles.urls()
+	}
+	return nil
+}
+
+func (p profiles) urls() []string {
+	var urls []string
+	for url := range p.byURL {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	return urls
+}
@@ -0,0 +1,207 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidator_ValidateAgainstProfiles(t *testing.T) {
+	profiled, _ := ioutil.ReadFile("../examples/profiled_consent.json")
+
+	withProfiles := func(profiles ...interface{}) []byte {
+		var consent map[string]interface{}
+		_ = json.Unmarshal(profiled, &consent)
+		consent["meta"].(map[string]interface{})["profile"] = profiles
+		data, _ := json.Marshal(consent)
+		return data
+	}
+
+	validator := &Validator{}
+	if err := validator.Configure(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("nested Nuts consent profile is loaded", func(t *testing.T) {
+		assert.Equal(t, []string{NutsConsentProfile}, validator.Profiles())
+	})
+
+	t.Run("valid against the Nuts consent profile", func(t *testing.T) {
+		valid, errs, err := validator.ValidateAgainstSchema(profiled)
+
+		assert.NoError(t, err)
+		assert.True(t, valid)
+		assert.Empty(t, errs)
+	})
+
+	t.Run("action not in the Nuts consent profile ValueSet", func(t *testing.T) {
+		var consent map[string]interface{}
+		_ = json.Unmarshal(profiled, &consent)
+		provision := consent["provision"].(map[string]interface{})["provision"].([]interface{})[0].(map[string]interface{})
+		provision["action"] = []interface{}{map[string]interface{}{"coding": []interface{}{
+			map[string]interface{}{"system": "http://terminology.hl7.org/CodeSystem/consentaction", "code": "collect"},
+		}}}
+		data, _ := json.Marshal(consent)
+
+		valid, errs, _ := validator.ValidateAgainstSchema(data)
+
+		assert.False(t, valid)
+		// reported by the rules of the Nuts consent profile and by its StructureDefinition
+		assert.Equal(t, []string{
+			"provision.provision.0.action.0: action must be one of access, correct, disclose from http://terminology.hl7.org/CodeSystem/consentaction",
+			"provision.provision.0.action.0: value must be a code from http://nuts.nl/fhir/ValueSet/nuts-consent-action in profile http://nuts.nl/fhir/StructureDefinition/nuts-consent",
+		}, messages(errs))
+	})
+
+	t.Run("versioned profile url", func(t *testing.T) {
+		valid, _, _ := validator.ValidateAgainstSchema(withProfiles(NutsConsentProfile + "|1.0.0"))
+
+		assert.True(t, valid)
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		valid, errs, _ := validator.ValidateAgainstSchema(withProfiles("http://example.org/StructureDefinition/unknown"))
+
+		assert.False(t, valid)
+		if assert.Len(t, errs, 1) {
+			assert.Equal(t, "profile.meta.profile-unknown", errs[0].Code)
+			assert.Equal(t, "/meta/profile/0", errs[0].Pointer)
+			assert.Equal(t, "meta.profile.0: profile http://example.org/StructureDefinition/unknown is not known by this node", errs[0].Message)
+		}
+	})
+
+	t.Run("profile of another FHIR version", func(t *testing.T) {
+		r5, _ := ioutil.ReadFile("../examples/r5_consent.json")
+		var consent map[string]interface{}
+		_ = json.Unmarshal(r5, &consent)
+		consent["meta"].(map[string]interface{})["profile"] = []interface{}{NutsConsentProfile}
+		data, _ := json.Marshal(consent)
+
+		valid, errs, _ := validator.ValidateAgainstSchemaVersion(data, FHIRVersionR5)
+
+		assert.False(t, valid)
+		assert.Equal(t, []string{"meta.profile.0: profile http://nuts.nl/fhir/StructureDefinition/nuts-consent is defined for FHIR 4.0"}, messages(errs))
+	})
+
+	t.Run("profile given by the caller", func(t *testing.T) {
+		data, _ := ioutil.ReadFile("../examples/observation_consent.json")
+		withoutDateTime := []byte(strings.Replace(string(data), `"dateTime": "2016-06-23T17:02:33+10:00",`, "", 1))
+
+		errs, err := validator.ValidateAgainstProfile(data, "", NutsConsentProfile)
+		assert.NoError(t, err)
+		assert.Empty(t, errs)
+
+		errs, err = validator.ValidateAgainstProfile(withoutDateTime, "", NutsConsentProfile+"|1.0")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"(root): dateTime is required by profile http://nuts.nl/fhir/StructureDefinition/nuts-consent"}, messages(errs))
+	})
+
+	t.Run("profile given by the caller and in meta.profile is applied once", func(t *testing.T) {
+		data, _ := ioutil.ReadFile("../examples/profiled_consent.json")
+
+		errs, err := validator.ValidateAgainstProfile(data, "", NutsConsentProfile)
+
+		assert.NoError(t, err)
+		assert.Empty(t, errs)
+	})
+
+	t.Run("profile given by the caller that can not be applied", func(t *testing.T) {
+		data, _ := ioutil.ReadFile("../examples/observation_consent.json")
+
+		_, err := validator.ValidateAgainstProfile(data, "", "http://example.org/StructureDefinition/unknown")
+		assert.True(t, errors.Is(err, ErrProfileNotApplicable))
+
+		r5, _ := ioutil.ReadFile("../examples/r5_consent.json")
+		_, err = validator.ValidateAgainstProfile(r5, FHIRVersionR5, NutsConsentProfile)
+		assert.EqualError(t, err, "profile can not be applied: profile http://nuts.nl/fhir/StructureDefinition/nuts-consent is defined for FHIR 4.0")
+	})
+
+	t.Run("without meta.profile no profile applies", func(t *testing.T) {
+		data, _ := ioutil.ReadFile("../examples/observation_consent.json")
+
+		valid, _, _ := validator.ValidateAgainstSchema(data)
+
+		assert.True(t, valid)
+	})
+}
+
+func TestLoadProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		_ = os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("directory is searched recursively", func(t *testing.T) {
+		write("nested/test.json", testProfile)
+		write("valueset.JSON", `{"resourceType": "ValueSet", "url": "http://example.org/ValueSet/policy", "compose": {"include": [{"system": "s"}]}}`)
+		write("readme.txt", "not a profile")
+
+		p, err := loadProfiles(dir, ioutil.Discard)
+
+		if assert.NoError(t, err) {
+			assert.Len(t, p.byURL, 2)
+			assert.Contains(t, p.byURL, "http://example.org/StructureDefinition/test")
+			assert.Contains(t, p.valueSets, "http://example.org/ValueSet/policy")
+		}
+	})
+
+	t.Run("comma separated list of files", func(t *testing.T) {
+		bundle := write("list/bundle.json", `{"resourceType": "Bundle", "entry": [{"resource": `+testProfile+`}]}`)
+
+		p, err := loadProfiles(bundle+", "+filepath.Join(dir, "valueset.JSON"), ioutil.Discard)
+
+		if assert.NoError(t, err) {
+			assert.Contains(t, p.byURL, "http://example.org/StructureDefinition/test")
+			assert.Contains(t, p.valueSets, "http://example.org/ValueSet/policy")
+		}
+	})
+
+	t.Run("unsupported resource", func(t *testing.T) {
+		path := write("invalid/patient.json", `{"resourceType": "Patient"}`)
+
+		_, err := loadProfiles(path, ioutil.Discard)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := loadProfiles(filepath.Join(dir, "missing.json"), ioutil.Discard)
+
+		assert.Error(t, err)
+	})
+}
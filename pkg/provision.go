@@ -0,0 +1,207 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import "fmt"
+
+// Provision types
+const (
+	ProvisionPermit = "permit"
+	ProvisionDeny   = "deny"
+)
+
+// provisionRule is a single provision from the tree with all inherited values filled in
+type provisionRule struct {
+	// field is the "provision.provision.0" style location of the provision
+	field   string
+	kind    string
+	actors  []Identifier
+	classes []string
+	actions []string
+	// periods are the periods of the provision and its parents, the provision applies within all of them
+	periods []fieldPeriod
+}
+
+// fieldPeriod is a period with the "provision.provision.0.period" style location it is defined at
+type fieldPeriod struct {
+	field  string
+	period *Period
+}
+
+// rules flattens the provision tree. A nested provision inherits the type, actors, classes and actions of its parent when it does not define them itself,
+// its period narrows the period of its parent.
+// A provision without type at the root grants nothing by itself, it only holds the values inherited by the nested provisions.
+func (c *Consent) rules() ([]provisionRule, error) {
+	if c.Provision == nil {
+		return nil, missingValue("provision")
+	}
+
+	var rules []provisionRule
+	err := c.Provision.walk("provision", provisionRule{}, func(rule provisionRule) {
+		rules = append(rules, rule)
+	})
+	return rules, err
+}
+
+func (p *Provision) walk(field string, parent provisionRule, visit func(provisionRule)) error {
+	rule := provisionRule{
+		field:   field,
+		kind:    parent.kind,
+		actors:  parent.actors,
+		classes: parent.classes,
+		actions: parent.actions,
+		periods: parent.periods,
+	}
+
+	if p.Type != "" {
+		if p.Type != ProvisionPermit && p.Type != ProvisionDeny {
+			return invalidValue(field+".type", p.Type)
+		}
+		rule.kind = p.Type
+	}
+	if len(p.Actor) > 0 {
+		rule.actors = nil
+		for i, actor := range p.Actor {
+			identifier, err := identifierFrom(fmt.Sprintf("%s.actor.%d.reference", field, i), &actor.Reference)
+			if err != nil {
+				return err
+			}
+			rule.actors = append(rule.actors, Identifier(identifier))
+		}
+	}
+	if len(p.Class) > 0 {
+		rule.classes = nil
+		for i, class := range p.Class {
+			if class.System == "" || class.Code == "" {
+				return invalidValue(fmt.Sprintf("%s.class.%d", field, i), class.System+class.Code)
+			}
+			rule.classes = append(rule.classes, class.String())
+		}
+	}
+	if len(p.Action) > 0 {
+		rule.actions = nil
+		for _, action := range p.Action {
+			for _, coding := range action.Coding {
+				rule.actions = append(rule.actions, coding.Code)
+			}
+		}
+	}
+	if p.Period != nil {
+		// copy, the periods of the parent are shared with its other nested provisions
+		rule.periods = append(append([]fieldPeriod{}, parent.periods...), fieldPeriod{field: field + ".period", period: p.Period})
+	}
+
+	visit(rule)
+
+	for i := range p.Provision {
+		if err := p.Provision[i].walk(fmt.Sprintf("%s.provision.%d", field, i), rule, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PermittedClasses evaluates the provision tree and returns the classes every actor is permitted to access.
+// A class is permitted when a permit provision for the actor grants it and no deny provision for the actor excludes it, a deny always wins.
+func (c *Consent) PermittedClasses() (map[Identifier][]string, error) {
+	rules, err := c.rules()
+	if err != nil {
+		return nil, err
+	}
+
+	_, permitted := evaluate(rules)
+	return permitted, nil
+}
+
+// permittedActions evaluates the provision tree like PermittedClasses and returns the actors in order of appearance
+// with the actions of the permit provisions for every class permitted to them
+func (c *Consent) permittedActions() ([]Identifier, map[Identifier]map[string][]string, error) {
+	rules, err := c.rules()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	actors, permitted := evaluate(rules)
+	result := map[Identifier]map[string][]string{}
+	for _, actor := range actors {
+		result[actor] = map[string][]string{}
+		for _, class := range permitted[actor] {
+			result[actor][class] = []string{}
+		}
+	}
+	for _, rule := range rules {
+		if rule.kind != ProvisionPermit {
+			continue
+		}
+		for _, actor := range rule.actors {
+			for _, class := range rule.classes {
+				actions, ok := result[actor][class]
+				if !ok {
+					continue
+				}
+				for _, action := range rule.actions {
+					if !contains(actions, action) {
+						actions = append(actions, action)
+					}
+				}
+				result[actor][class] = actions
+			}
+		}
+	}
+	return actors, result, nil
+}
+
+// evaluate returns the actors in order of appearance and the classes permitted to each of them
+func evaluate(rules []provisionRule) ([]Identifier, map[Identifier][]string) {
+	var actors []Identifier
+	denied := map[Identifier]map[string]bool{}
+	for _, rule := range rules {
+		for _, actor := range rule.actors {
+			if _, ok := denied[actor]; !ok {
+				actors = append(actors, actor)
+				denied[actor] = map[string]bool{}
+			}
+			if rule.kind == ProvisionDeny {
+				for _, class := range rule.classes {
+					denied[actor][class] = true
+				}
+			}
+		}
+	}
+
+	permitted := map[Identifier][]string{}
+	for _, actor := range actors {
+		permitted[actor] = []string{}
+	}
+	for _, rule := range rules {
+		if rule.kind != ProvisionPermit {
+			continue
+		}
+		for _, actor := range rule.actors {
+			for _, class := range rule.classes {
+				if !denied[actor][class] && !contains(permitted[actor], class) {
+					permitted[actor] = append(permitted[actor], class)
+				}
+			}
+		}
+	}
+
+	return actors, permitted
+}
@@ -0,0 +1,112 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const treeConsent = `{
+  "resourceType": "Consent",
+  "provision": {
+    "actor": [
+      {"reference": {"identifier": {"system": "urn:oid:2.16.840.1.113883.2.4.6.1", "value": "00000007"}}},
+      {"reference": {"identifier": {"system": "urn:oid:2.16.840.1.113883.2.4.6.1", "value": "00000008"}}}
+    ],
+    "period": {"start": "2016-06-23T17:02:33+10:00"},
+    "provision": [
+      {
+        "type": "permit",
+        "class": [
+          {"system": "urn:oid:1.3.6.1.4.1.54851.1", "code": "MEDICAL"},
+          {"system": "urn:oid:1.3.6.1.4.1.54851.1", "code": "SOCIAL"}
+        ],
+        "provision": [
+          {
+            "type": "deny",
+            "actor": [
+              {"reference": {"identifier": {"system": "urn:oid:2.16.840.1.113883.2.4.6.1", "value": "00000008"}}}
+            ],
+            "class": [{"system": "urn:oid:1.3.6.1.4.1.54851.1", "code": "SOCIAL"}]
+          }
+        ]
+      },
+      {
+        "type": "deny",
+        "class": [{"system": "urn:oid:1.3.6.1.4.1.54851.1", "code": "MENTAL"}]
+      },
+      {
+        "type": "permit",
+        "class": [{"system": "urn:oid:1.3.6.1.4.1.54851.1", "code": "MENTAL"}]
+      }
+    ]
+  }
+}`
+
+func TestConsent_PermittedClasses(t *testing.T) {
+	consent, _ := ParseConsent([]byte(treeConsent))
+
+	t.Run("deny provisions are applied per actor", func(t *testing.T) {
+		permitted, err := consent.PermittedClasses()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, map[Identifier][]string{
+			"urn:oid:2.16.840.1.113883.2.4.6.1:00000007": {"urn:oid:1.3.6.1.4.1.54851.1:MEDICAL", "urn:oid:1.3.6.1.4.1.54851.1:SOCIAL"},
+			"urn:oid:2.16.840.1.113883.2.4.6.1:00000008": {"urn:oid:1.3.6.1.4.1.54851.1:MEDICAL"},
+		}, permitted)
+	})
+
+	t.Run("data classes hold the classes permitted to any actor", func(t *testing.T) {
+		classes, err := consent.DataClasses()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []string{"urn:oid:1.3.6.1.4.1.54851.1:MEDICAL", "urn:oid:1.3.6.1.4.1.54851.1:SOCIAL"}, classes)
+	})
+
+	t.Run("unknown provision type", func(t *testing.T) {
+		consent, _ := ParseConsent([]byte(`{"resourceType": "Consent", "provision": {"provision": [{"type": "maybe"}]}}`))
+
+		_, err := consent.PermittedClasses()
+
+		assert.EqualError(t, err, "provision.provision.0.type: value [maybe] can not be extracted")
+	})
+
+	t.Run("values are inherited from the parent provision", func(t *testing.T) {
+		rules, err := consent.rules()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Len(t, rules, 5)
+		assert.Equal(t, "provision.provision.0.provision.0", rules[2].field)
+		assert.Equal(t, ProvisionDeny, rules[2].kind)
+		assert.Equal(t, []Identifier{"urn:oid:2.16.840.1.113883.2.4.6.1:00000008"}, rules[2].actors)
+		if assert.Len(t, rules[2].periods, 1) {
+			assert.Equal(t, "provision.period", rules[2].periods[0].field)
+			assert.Equal(t, "2016-06-23T17:02:33+10:00", rules[2].periods[0].period.Start)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// --maxSize config flag
+const ConfigMaxSize = "maxSize"
+
+// default maximum size of a document in bytes
+const ConfigMaxSizeDefault = 16 * 1024 * 1024
+
+// --readTimeout config flag
+const ConfigReadTimeout = "readTimeout"
+
+// default timeout in seconds for reading a document
+const ConfigReadTimeoutDefault = 30
+
+// ErrDocumentTooLarge is returned when a document exceeds the configured maximum size
+var ErrDocumentTooLarge = errors.New("document exceeds the maximum size")
+
+// ErrReadTimeout is returned when a document could not be read within the configured timeout
+var ErrReadTimeout = errors.New("document could not be read within the timeout")
+
+// ReadDocument reads a single document from the reader. Reading stops when the document exceeds the maximum size or when the timeout passes.
+// See ReadDocumentContext for how the timeout ends a pending read.
+func (ve *Validator) ReadDocument(reader io.Reader) ([]byte, error) {
+	return ve.ReadDocumentContext(context.Background(), reader)
+}
+
+// ReadDocumentContext reads a single document from the reader within the read timeout and the deadline of the context, eg: of an http request.
+// Reading stops when the document exceeds the maximum size, the deadline passes or the context is cancelled. The document is read without
+// starting a goroutine: readers that support a read deadline, like files, pipes, network connections and a request body on a known
+// connection, get the deadline and return when it passes. Other readers are checked before every read, a read that blocks is not ended.
+func (ve *Validator) ReadDocumentContext(ctx context.Context, reader io.Reader) ([]byte, error) {
+	if ve.Config.ReadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(ve.Config.ReadTimeout)*time.Second)
+		defer cancel()
+	}
+	var deadline deadliner
+	if d, ok := reader.(deadliner); ok {
+		// a file without deadline support returns an error, it is checked by the contextReader
+		if t, ok := ctx.Deadline(); ok && d.SetReadDeadline(t) == nil {
+			deadline = d
+		}
+	}
+
+	maxSize := int64(ve.Config.MaxSize)
+	if maxSize > 0 {
+		// one more byte to detect an oversized document without reading it completely
+		reader = io.LimitReader(reader, maxSize+1)
+	}
+
+	data, err := ioutil.ReadAll(contextReader{ctx: ctx, reader: reader})
+	timeout := errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded)
+	if deadline != nil && !timeout {
+		deadline.SetReadDeadline(time.Time{})
+	}
+	switch {
+	case timeout:
+		// the passed deadline is kept, an http server that discards the rest of the request body then stops instead of waiting for it
+		return nil, fmt.Errorf("%w of %d seconds", ErrReadTimeout, ve.Config.ReadTimeout)
+	case err != nil:
+		return nil, err
+	case maxSize > 0 && int64(len(data)) > maxSize:
+		return nil, fmt.Errorf("%w of %d bytes", ErrDocumentTooLarge, maxSize)
+	}
+	return data, nil
+}
+
+// deadliner is implemented by readers that can interrupt a pending read, eg: *os.File and net.Conn
+type deadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// contextReader stops reading when the context is done
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.reader.Read(p)
+}
+
+// readError converts an error from ReadDocument, a document that is too large or too slow is reported with its own syntax code
+func readError(err error) ValidationError {
+	ve := SyntaxError(err)
+	switch {
+	case errors.Is(err, ErrDocumentTooLarge):
+		ve.Code = CodeSyntax + ".too-large"
+	case errors.Is(err, ErrReadTimeout):
+		ve.Code = CodeSyntax + ".read-timeout"
+	}
+	return ve
+}
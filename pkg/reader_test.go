@@ -0,0 +1,145 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidator_ReadDocument(t *testing.T) {
+	t.Run("document within maximum size", func(t *testing.T) {
+		v := &Validator{}
+		v.Config.MaxSize = 2
+
+		data, err := v.ReadDocument(strings.NewReader("{}"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "{}", string(data))
+	})
+
+	t.Run("document exceeding maximum size", func(t *testing.T) {
+		v := &Validator{}
+		v.Config.MaxSize = 2
+
+		_, err := v.ReadDocument(strings.NewReader("{ }"))
+
+		assert.True(t, errors.Is(err, ErrDocumentTooLarge))
+		assert.Equal(t, "document exceeds the maximum size of 2 bytes", err.Error())
+	})
+
+	t.Run("0 is unlimited", func(t *testing.T) {
+		data, err := (&Validator{}).ReadDocument(strings.NewReader(strings.Repeat(" ", 1024)))
+
+		assert.NoError(t, err)
+		assert.Len(t, data, 1024)
+	})
+
+	t.Run("slow document", func(t *testing.T) {
+		v := &Validator{}
+		v.Config.ReadTimeout = 1
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reader.Close()
+		defer writer.Close()
+
+		start := time.Now()
+		_, err = v.ReadDocument(reader)
+
+		assert.True(t, errors.Is(err, ErrReadTimeout))
+		assert.True(t, time.Since(start) < 2*time.Second)
+	})
+
+	t.Run("slow document without read deadline", func(t *testing.T) {
+		v := &Validator{}
+		v.Config.ReadTimeout = 1
+
+		start := time.Now()
+		_, err := v.ReadDocument(stalledReader{interval: 100 * time.Millisecond})
+
+		assert.True(t, errors.Is(err, ErrReadTimeout))
+		assert.True(t, time.Since(start) < 2*time.Second)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := (&Validator{}).ReadDocumentContext(ctx, strings.NewReader("{}"))
+
+		assert.Equal(t, context.Canceled, err)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		reader, writer := io.Pipe()
+		writer.CloseWithError(errors.New("b00m!"))
+
+		_, err := (&Validator{}).ReadDocument(reader)
+
+		assert.EqualError(t, err, "b00m!")
+	})
+}
+
+func TestValidator_ValidateReader(t *testing.T) {
+	t.Run("document exceeding maximum size is a syntax error", func(t *testing.T) {
+		v := &Validator{}
+		v.Config.MaxSize = 2
+
+		report, err := v.ValidateReader(strings.NewReader("{ }"))
+
+		assert.NoError(t, err)
+		assert.False(t, report.Valid())
+		if assert.Len(t, report.Errors, 1) {
+			assert.Equal(t, TypeSyntax, report.Errors[0].Type)
+			assert.Equal(t, "syntax.too-large", report.Errors[0].Code)
+		}
+	})
+
+	t.Run("slow document is a syntax error", func(t *testing.T) {
+		v := &Validator{}
+		v.Config.ReadTimeout = 1
+
+		report, err := v.ValidateReader(stalledReader{interval: 100 * time.Millisecond})
+
+		assert.NoError(t, err)
+		if assert.Len(t, report.Errors, 1) {
+			assert.Equal(t, "syntax.read-timeout", report.Errors[0].Code)
+		}
+	})
+}
+
+// stalledReader keeps the connection open without sending any data
+type stalledReader struct {
+	interval time.Duration
+}
+
+func (s stalledReader) Read(p []byte) (int, error) {
+	time.Sleep(s.interval)
+	return 0, nil
+}
@@ -0,0 +1,111 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const definitionsPrefix = "#/definitions/"
+
+// resourceListDefinition is the definition referring to all resources, it is used for contained resources
+const resourceListDefinition = "ResourceList"
+
+// reduceSchema derives a schema from the full FHIR json schema that only holds the given resource type and the definitions it transitively refers to.
+// Contained resources refer to every resource type through the ResourceList, in the reduced schema they are only required to have a resourceType.
+func reduceSchema(data []byte, resourceType string) ([]byte, error) {
+	var full map[string]interface{}
+	if err := json.Unmarshal(data, &full); err != nil {
+		return nil, err
+	}
+
+	definitions, _ := full["definitions"].(map[string]interface{})
+	if _, ok := definitions[resourceType]; !ok {
+		return nil, fmt.Errorf("schema has no definition for %s", resourceType)
+	}
+
+	reduced := map[string]interface{}{}
+	todo := []string{resourceType}
+	for len(todo) > 0 {
+		name := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if _, ok := reduced[name]; ok {
+			continue
+		}
+		definition, ok := definitions[name]
+		if !ok {
+			return nil, fmt.Errorf("schema has no definition for %s, referred to from %s", name, resourceType)
+		}
+		if name == resourceListDefinition {
+			reduced[name] = map[string]interface{}{
+				"description": "Contained resources are not validated by the reduced schema",
+				"type":        "object",
+				"properties":  map[string]interface{}{"resourceType": map[string]interface{}{"type": "string"}},
+				"required":    []interface{}{"resourceType"},
+			}
+			continue
+		}
+		reduced[name] = definition
+		todo = append(todo, referencesIn(definition)...)
+	}
+
+	ref := map[string]interface{}{"$ref": definitionsPrefix + resourceType}
+	result := map[string]interface{}{
+		"definitions": reduced,
+		"oneOf":       []interface{}{ref},
+	}
+	for _, key := range []string{"$schema", "id", "description"} {
+		if value, ok := full[key]; ok {
+			result[key] = value
+		}
+	}
+	if discriminator, ok := full["discriminator"].(map[string]interface{}); ok {
+		result["discriminator"] = map[string]interface{}{
+			"propertyName": discriminator["propertyName"],
+			"mapping":      map[string]interface{}{resourceType: definitionsPrefix + resourceType},
+		}
+	}
+
+	return json.Marshal(result)
+}
+
+// referencesIn returns the names of all definitions referred to with $ref
+func referencesIn(value interface{}) []string {
+	var refs []string
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if ref, ok := child.(string); key == "$ref" && ok && strings.HasPrefix(ref, definitionsPrefix) {
+				refs = append(refs, strings.TrimPrefix(ref, definitionsPrefix))
+				continue
+			}
+			refs = append(refs, referencesIn(child)...)
+		}
+	case []interface{}:
+		for _, child := range v {
+			refs = append(refs, referencesIn(child)...)
+		}
+	}
+
+	return refs
+}
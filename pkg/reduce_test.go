@@ -0,0 +1,66 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/nuts-foundation/nuts-fhir-validation/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReduceSchema(t *testing.T) {
+	data, _ := schema.Asset("fhir.schema.json")
+
+	t.Run("only Consent and its references remain", func(t *testing.T) {
+		reduced, err := reduceSchema(data, "Consent")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		var result struct {
+			OneOf         []map[string]string               `json:"oneOf"`
+			Discriminator map[string]interface{}            `json:"discriminator"`
+			Definitions   map[string]map[string]interface{} `json:"definitions"`
+		}
+		json.Unmarshal(reduced, &result)
+
+		assert.True(t, len(reduced) < len(data)/10)
+		assert.Equal(t, []map[string]string{{"$ref": "#/definitions/Consent"}}, result.OneOf)
+		assert.Equal(t, map[string]interface{}{"Consent": "#/definitions/Consent"}, result.Discriminator["mapping"])
+		assert.Contains(t, result.Definitions, "Consent_Provision")
+		assert.Contains(t, result.Definitions, "Identifier")
+		assert.NotContains(t, result.Definitions, "Patient")
+		assert.Equal(t, "object", result.Definitions["ResourceList"]["type"])
+	})
+
+	t.Run("unknown resource type", func(t *testing.T) {
+		_, err := reduceSchema(data, "Unknown")
+
+		assert.EqualError(t, err, "schema has no definition for Unknown")
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		_, err := reduceSchema([]byte("{"), "Consent")
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,179 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// Outcomes of a ValidationReport
+const (
+	OutcomeValid   = "valid"
+	OutcomeInvalid = "invalid"
+)
+
+// SimplifiedConsent holds the Nuts fields extracted from a valid consent record
+type SimplifiedConsent struct {
+	Subject   Identifier   `json:"subject"`
+	Custodian Identifier   `json:"custodian"`
+	Actors    []Identifier `json:"actors"`
+	// Resources are the permitted classes
+	Resources []string `json:"resources"`
+}
+
+// ValidationReport is the result of all validation stages: schema and Nuts profile, node policy and extraction of the simplified consent
+type ValidationReport struct {
+	// Outcome is valid or invalid
+	Outcome string `json:"outcome"`
+	// FHIRVersion the record is validated for
+	FHIRVersion string `json:"fhirVersion,omitempty"`
+	// Errors found by the first stage that failed
+	Errors []ValidationError `json:"validationErrors,omitempty"`
+	// Consent is only present for a valid record
+	Consent *SimplifiedConsent `json:"consent,omitempty"`
+}
+
+// Valid returns true when the outcome is valid
+func (r ValidationReport) Valid() bool {
+	return r.Outcome == OutcomeValid
+}
+
+func invalidReport(errs []ValidationError) *ValidationReport {
+	return &ValidationReport{Outcome: OutcomeInvalid, Errors: errs}
+}
+
+// Validate runs all validation stages on the consent record of the configured FHIR version.
+// Errors in the document are part of the report, the returned error is only used for processing failures.
+func (ve *Validator) Validate(json []byte) (*ValidationReport, error) {
+	return ve.ValidateFHIRVersion(json, ve.Config.Version)
+}
+
+// ValidateFHIRVersion runs all validation stages on the consent record of the given FHIR version.
+// The Nuts consent profile, class registry, node policy and simplified consent are defined on R4, records of other versions are mapped onto R4 after their schema.
+// An empty FHIR version is the configured version, an error is returned for a FHIR version without schema.
+// All stages use the rules that are active when the validation starts.
+func (ve *Validator) ValidateFHIRVersion(json []byte, fhirVersion string) (*ValidationReport, error) {
+	fhirVersion, err := ve.fhirVersion(fhirVersion)
+	if err != nil {
+		return nil, err
+	}
+	r := ve.current()
+	report := func(errs []ValidationError) *ValidationReport {
+		r := invalidReport(errs)
+		r.FHIRVersion = fhirVersion
+		return r
+	}
+
+	valid, errs, err := r.validateSchema(json, fhirVersion)
+	if err == ErrNotConfigured {
+		return nil, err
+	}
+	if err != nil {
+		return report([]ValidationError{SyntaxError(err)}), nil
+	}
+	if !valid {
+		return report(errs), nil
+	}
+	if json, err = mapJSONToR4(json, fhirVersion); err != nil {
+		return nil, err
+	}
+
+	policyErrors, err := r.validatePolicy(json)
+	if err != nil {
+		return nil, err
+	}
+	if len(policyErrors) > 0 {
+		return report(policyErrors), nil
+	}
+
+	consent, extractErrors := ExtractSimplifiedConsent(json)
+	if len(extractErrors) > 0 {
+		return report(extractErrors), nil
+	}
+
+	return &ValidationReport{Outcome: OutcomeValid, FHIRVersion: fhirVersion, Consent: consent}, nil
+}
+
+// ValidateFile runs all validation stages on the consent record at the given location (on disk)
+func (ve *Validator) ValidateFile(source string) (*ValidationReport, error) {
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	return ve.Validate(data)
+}
+
+// ValidateReader runs all validation stages on the consent record read from the reader.
+// A record that exceeds the maximum size or can not be read within the timeout results in a report with a syntax error.
+func (ve *Validator) ValidateReader(reader io.Reader) (*ValidationReport, error) {
+	data, err := ve.ReadDocument(reader)
+	if errors.Is(err, ErrDocumentTooLarge) || errors.Is(err, ErrReadTimeout) {
+		return invalidReport([]ValidationError{readError(err)}), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ve.Validate(data)
+}
+
+// ExtractSimplifiedConsent extracts the simplified consent from the consent record.
+// Every value that can not be extracted results in a ValidationError, the consent is only returned when there are none.
+func ExtractSimplifiedConsent(data []byte) (*SimplifiedConsent, []ValidationError) {
+	consent, err := ParseConsent(data)
+	if err != nil {
+		return nil, []ValidationError{ErrorFrom(err)}
+	}
+
+	var errs []ValidationError
+	collect := func(err error) {
+		if err == nil {
+			return
+		}
+		ve := ErrorFrom(err)
+		for _, e := range errs {
+			if e.Code == ve.Code && e.Pointer == ve.Pointer {
+				return
+			}
+		}
+		errs = append(errs, ve)
+	}
+
+	subject, err := consent.Subject()
+	collect(err)
+	custodian, err := consent.Custodian()
+	collect(err)
+	actors, err := consent.Actors()
+	collect(err)
+	resources, err := consent.DataClasses()
+	collect(err)
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return &SimplifiedConsent{
+		Subject:   Identifier(subject),
+		Custodian: Identifier(custodian),
+		Actors:    actors,
+		Resources: resources,
+	}, nil
+}
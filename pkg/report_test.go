@@ -0,0 +1,115 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidator_Validate(t *testing.T) {
+	client := validationBackend()
+	data, _ := ioutil.ReadFile("../examples/observation_consent.json")
+
+	t.Run("valid consent has simplified consent", func(t *testing.T) {
+		report, err := client.Validate(data)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.True(t, report.Valid())
+		assert.Empty(t, report.Errors)
+		assert.Equal(t, &SimplifiedConsent{
+			Subject:   "urn:oid:2.16.840.1.113883.2.4.6.3:999999990",
+			Custodian: "urn:oid:2.16.840.1.113883.2.4.6.1:00000000",
+			Actors:    []Identifier{"urn:oid:2.16.840.1.113883.2.4.6.1:00000007"},
+			Resources: []string{"http://hl7.org/fhir/resource-types#Observation", "urn:oid:1.3.6.1.4.1.54851.1:MEDICAL"},
+		}, report.Consent)
+	})
+
+	t.Run("invalid consent has errors", func(t *testing.T) {
+		report, err := client.Validate([]byte("{}"))
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, OutcomeInvalid, report.Outcome)
+		assert.Len(t, report.Errors, 4)
+		assert.Nil(t, report.Consent)
+	})
+
+	t.Run("broken json is a syntax error", func(t *testing.T) {
+		report, _ := client.Validate([]byte("{"))
+
+		assert.Equal(t, TypeSyntax, report.Errors[0].Type)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		report, err := client.ValidateFile("../examples/observation_consent.json")
+
+		assert.NoError(t, err)
+		assert.True(t, report.Valid())
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := client.ValidateFile("../examples/none.json")
+
+		assert.Error(t, err)
+	})
+
+	t.Run("reader", func(t *testing.T) {
+		report, err := client.ValidateReader(bytes.NewReader(data))
+
+		assert.NoError(t, err)
+		assert.True(t, report.Valid())
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		_, err := (&Validator{}).Validate(data)
+
+		assert.Equal(t, ErrNotConfigured, err)
+	})
+}
+
+func TestExtractSimplifiedConsent(t *testing.T) {
+	t.Run("missing values are returned as errors", func(t *testing.T) {
+		data := []byte(`{"resourceType": "Consent", "patient": {"reference": "Patient/1"}, "provision": {"actor": [{"reference": {"reference": "Organization/1"}}]}}`)
+
+		consent, errs := ExtractSimplifiedConsent(data)
+
+		assert.Nil(t, consent)
+		if assert.Len(t, errs, 3) {
+			assert.Equal(t, "/patient/identifier", errs[0].Pointer)
+			assert.Equal(t, "/organization", errs[1].Pointer)
+			assert.Equal(t, "extract.provision.actor.reference.identifier-invalid", errs[2].Code)
+		}
+	})
+
+	t.Run("broken json is a syntax error", func(t *testing.T) {
+		_, errs := ExtractSimplifiedConsent([]byte("{"))
+
+		if assert.Len(t, errs, 1) {
+			assert.Equal(t, TypeSyntax, errs[0].Type)
+		}
+	})
+}
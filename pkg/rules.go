@@ -0,0 +1,157 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/nuts-foundation/nuts-fhir-validation/schema"
+	"github.com/sirupsen/logrus"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// ConfigReload is the config name for allowing POST /admin/reload
+const ConfigReload = "reload"
+
+// ConfigReloadDefault is false, reloading is not allowed unless configured
+const ConfigReloadDefault = false
+
+// rules holds the compiled schemas, profiles, class registry and policy a Validator validates with.
+// Loaded rules are never changed, Reload replaces them as a whole.
+type rules struct {
+	// schemas holds the compiled Consent schema per FHIR version
+	schemas  map[string]*gojsonschema.Schema
+	profiles profiles
+	classes  ClassRegistry
+	policy   policy
+	info     RulesInfo
+}
+
+// RulesInfo identifies the rules a Validator validates with
+type RulesInfo struct {
+	// Hash is the sha256 of the schemas, profiles, class registry and policy settings or file
+	Hash string `json:"hash"`
+	// Version is 1 for the rules loaded by Configure and incremented by every Reload
+	Version  int       `json:"version"`
+	LoadedAt time.Time `json:"loadedAt"`
+	// FHIRVersions with a Consent schema
+	FHIRVersions []string `json:"fhirVersions"`
+	// Profiles are the urls of the loaded StructureDefinitions
+	Profiles []string `json:"profiles"`
+}
+
+// current returns the active rules, nil when no rules are loaded
+func (ve *Validator) current() *rules {
+	r, _ := ve.active.Load().(*rules)
+	return r
+}
+
+// Rules returns the info of the active rules
+func (ve *Validator) Rules() (RulesInfo, error) {
+	r := ve.current()
+	if r == nil {
+		return RulesInfo{}, ErrNotConfigured
+	}
+	return r.info, nil
+}
+
+// Reload loads the schemas, profiles, class registry and policy from the configuration again and replaces the active rules.
+// Validations that have started keep using the previous rules. When loading fails, the active rules are kept and the error is returned.
+func (ve *Validator) Reload() (RulesInfo, error) {
+	ve.reloadMutex.Lock()
+	defer ve.reloadMutex.Unlock()
+
+	old := ve.current()
+	if old == nil {
+		return RulesInfo{}, ErrNotConfigured
+	}
+
+	r, err := ve.loadRules(old.info.Version + 1)
+	if err != nil {
+		logrus.Errorf("Reloading validation rules failed, keeping version %d: %s", old.info.Version, err.Error())
+		return old.info, err
+	}
+
+	ve.active.Store(r)
+	logrus.Infof("Reloaded validation rules, version %d with hash %s", r.info.Version, r.info.Hash)
+	return r.info, nil
+}
+
+// loadRules reads and compiles the schemas, profiles, class registry and policy of the configuration.
+// The R4 schema is read from the schemapath or nested Asset, the schemas of the other FHIR versions are always nested.
+func (ve *Validator) loadRules(version int) (*rules, error) {
+	digest := sha256.New()
+	r := &rules{schemas: map[string]*gojsonschema.Schema{}}
+
+	var err error
+	if r.policy, err = loadPolicy(ve.Config.Policy, digest); err != nil {
+		return nil, err
+	}
+
+	if r.profiles, err = loadProfiles(ve.Config.Profiles, digest); err != nil {
+		return nil, err
+	}
+
+	if r.classes, err = loadClassRegistry(ve.Config.Classregistry, digest); err != nil {
+		return nil, err
+	}
+
+	if err = loadConsentSchemas(r.schemas, digest); err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if ve.Config.Schemapath != ConfigSchemaPathDefault {
+		data, err = ioutil.ReadFile(ve.Config.Schemapath)
+	} else {
+		// load the bundled asset
+		data, err = schema.Asset("fhir.schema.json")
+	}
+	if err != nil {
+		return nil, err
+	}
+	digest.Write(data)
+	digest.Write([]byte(strconv.FormatBool(ve.Config.Fullschema)))
+
+	if !ve.Config.Fullschema {
+		if data, err = reduceSchema(data, consentResourceType); err != nil {
+			return nil, fmt.Errorf("unable to reduce schema to %s, use --%s to validate against the full schema: %w", consentResourceType, ConfigFullSchema, err)
+		}
+	}
+
+	// compile once, the compiled schemas are safe for concurrent use
+	if r.schemas[FHIRVersionR4], err = gojsonschema.NewSchema(gojsonschema.NewBytesLoader(data)); err != nil {
+		return nil, err
+	}
+
+	r.info = RulesInfo{
+		Hash:         hex.EncodeToString(digest.Sum(nil)),
+		Version:      version,
+		LoadedAt:     time.Now(),
+		FHIRVersions: r.fhirVersions(),
+		Profiles:     r.profiles.urls(),
+	}
+	return r, nil
+}
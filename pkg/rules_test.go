@@ -0,0 +1,187 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidator_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	profile := filepath.Join(dir, "test.json")
+
+	configured := func() *Validator {
+		v := &Validator{}
+		v.Config.Profiles = dir
+		if err := v.Configure(); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	t.Run("Configure loads version 1", func(t *testing.T) {
+		info, err := configured().Rules()
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, 1, info.Version)
+			assert.Len(t, info.Hash, 64)
+			assert.Equal(t, []string{FHIRVersionSTU3, FHIRVersionR4, FHIRVersionR5}, info.FHIRVersions)
+			assert.Equal(t, []string{NutsConsentProfile}, info.Profiles)
+			assert.False(t, info.LoadedAt.IsZero())
+		}
+	})
+
+	t.Run("unchanged configuration keeps the hash", func(t *testing.T) {
+		v := configured()
+		old, _ := v.Rules()
+
+		info, err := v.Reload()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, info.Version)
+		assert.Equal(t, old.Hash, info.Hash)
+	})
+
+	t.Run("changed profiles are picked up", func(t *testing.T) {
+		v := configured()
+		old, _ := v.Rules()
+		if err := ioutil.WriteFile(profile, []byte(testProfile), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(profile)
+
+		info, err := v.Reload()
+
+		assert.NoError(t, err)
+		assert.NotEqual(t, old.Hash, info.Hash)
+		assert.Contains(t, v.Profiles(), "http://example.org/StructureDefinition/test")
+	})
+
+	t.Run("changed policy is picked up", func(t *testing.T) {
+		v := configured()
+		bytes, _ := ioutil.ReadFile("../examples/observation_consent.json")
+		v.Config.Policy.Custodians = "urn:oid:2.16.840.1.113883.2.4.6.1:00000001"
+
+		before, _ := v.Validate(bytes)
+		_, err := v.Reload()
+		after, _ := v.Validate(bytes)
+
+		assert.NoError(t, err)
+		assert.True(t, before.Valid())
+		assert.False(t, after.Valid())
+	})
+
+	t.Run("changed policy file is picked up", func(t *testing.T) {
+		policyFile := filepath.Join(dir, "policy.yaml")
+		if err := ioutil.WriteFile(policyFile, []byte("maxperiod: 0\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(policyFile)
+		v := configured()
+		v.Config.Policy.File = policyFile
+		if _, err := v.Reload(); err != nil {
+			t.Fatal(err)
+		}
+		bytes, _ := ioutil.ReadFile("../examples/observation_consent.json")
+		before, _ := v.Validate(bytes)
+		old, _ := v.Rules()
+
+		if err := ioutil.WriteFile(policyFile, []byte("custodians:\n  - urn:oid:2.16.840.1.113883.2.4.6.1:00000001\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		info, err := v.Reload()
+		after, _ := v.Validate(bytes)
+
+		assert.NoError(t, err)
+		assert.NotEqual(t, old.Hash, info.Hash)
+		assert.True(t, before.Valid())
+		assert.False(t, after.Valid())
+	})
+
+	t.Run("invalid policy file fails", func(t *testing.T) {
+		policyFile := filepath.Join(dir, "policy.yaml")
+		if err := ioutil.WriteFile(policyFile, []byte("custodian: urn:oid:2.16.840.1.113883.2.4.6.1:00000001\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(policyFile)
+		v := configured()
+		v.Config.Policy.File = policyFile
+
+		_, err := v.Reload()
+
+		assert.Error(t, err)
+	})
+
+	t.Run("failed reload keeps the active rules", func(t *testing.T) {
+		v := configured()
+		old, _ := v.Rules()
+		if err := ioutil.WriteFile(profile, []byte(`{"resourceType": "Patient"}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(profile)
+
+		info, err := v.Reload()
+		active, _ := v.Rules()
+
+		assert.Error(t, err)
+		assert.Equal(t, old, info)
+		assert.Equal(t, old, active)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		v := &Validator{}
+
+		_, err := v.Reload()
+		assert.Equal(t, ErrNotConfigured, err)
+
+		_, err = v.Rules()
+		assert.Equal(t, ErrNotConfigured, err)
+	})
+
+	t.Run("validations continue during reload", func(t *testing.T) {
+		v := configured()
+		bytes, _ := ioutil.ReadFile("../examples/observation_consent.json")
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				report, err := v.Validate(bytes)
+				assert.NoError(t, err)
+				assert.True(t, report.Valid())
+			}()
+		}
+		_, err := v.Reload()
+		wg.Wait()
+
+		assert.NoError(t, err)
+	})
+}
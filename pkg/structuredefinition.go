@@ -0,0 +1,413 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// binding strength that is enforced, other strengths are only advisory
+const bindingRequired = "required"
+
+// StructureDefinition is the part of the FHIR StructureDefinition resource used to validate resources against a profile
+type StructureDefinition struct {
+	ResourceType   string `json:"resourceType"`
+	URL            string `json:"url"`
+	Name           string `json:"name,omitempty"`
+	FhirVersion    string `json:"fhirVersion,omitempty"`
+	Type           string `json:"type"`
+	BaseDefinition string `json:"baseDefinition,omitempty"`
+	Snapshot       *struct {
+		Element []ElementDefinition `json:"element"`
+	} `json:"snapshot,omitempty"`
+	Differential *struct {
+		Element []ElementDefinition `json:"element"`
+	} `json:"differential,omitempty"`
+}
+
+// ElementDefinition holds the constraints on a single element of a StructureDefinition
+type ElementDefinition struct {
+	ID        string          `json:"id,omitempty"`
+	Path      string          `json:"path"`
+	SliceName string          `json:"sliceName,omitempty"`
+	Min       *int            `json:"min,omitempty"`
+	Max       string          `json:"max,omitempty"`
+	Binding   *ElementBinding `json:"binding,omitempty"`
+	// Fixed is the value of the fixed[x] element, the value must be exactly equal
+	Fixed interface{} `json:"-"`
+	// Pattern is the value of the pattern[x] element, the value must contain the pattern
+	Pattern interface{} `json:"-"`
+}
+
+// ElementBinding binds a coded element to a ValueSet
+type ElementBinding struct {
+	Strength string `json:"strength"`
+	ValueSet string `json:"valueSet,omitempty"`
+}
+
+// UnmarshalJSON reads the polymorphic fixed[x] and pattern[x] elements into Fixed and Pattern
+func (ed *ElementDefinition) UnmarshalJSON(data []byte) error {
+	type plain ElementDefinition
+	if err := json.Unmarshal(data, (*plain)(ed)); err != nil {
+		return err
+	}
+
+	var elements map[string]interface{}
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return err
+	}
+	for k, v := range elements {
+		switch {
+		case isChoice(k, "fixed"):
+			ed.Fixed = v
+		case isChoice(k, "pattern"):
+			ed.Pattern = v
+		}
+	}
+	return nil
+}
+
+// ValueSet is the part of the FHIR ValueSet resource used to check required bindings
+type ValueSet struct {
+	ResourceType string `json:"resourceType"`
+	URL          string `json:"url"`
+	Compose      *struct {
+		Include []struct {
+			System  string `json:"system"`
+			Concept []struct {
+				Code string `json:"code"`
+			} `json:"concept,omitempty"`
+		} `json:"include"`
+	} `json:"compose,omitempty"`
+	Expansion *struct {
+		Contains []valueSetContains `json:"contains,omitempty"`
+	} `json:"expansion,omitempty"`
+}
+
+type valueSetContains struct {
+	System   string             `json:"system"`
+	Code     string             `json:"code"`
+	Contains []valueSetContains `json:"contains,omitempty"`
+}
+
+// codes is the parsed form of a ValueSet, a system without codes includes all codes of the system
+type codes map[string][]string
+
+func (vs ValueSet) codes() codes {
+	c := codes{}
+	if vs.Compose != nil {
+		for _, include := range vs.Compose.Include {
+			if _, ok := c[include.System]; !ok {
+				c[include.System] = nil
+			}
+			for _, concept := range include.Concept {
+				c[include.System] = append(c[include.System], concept.Code)
+			}
+		}
+	}
+	if vs.Expansion != nil {
+		c.addContains(vs.Expansion.Contains)
+	}
+	return c
+}
+
+func (c codes) addContains(contains []valueSetContains) {
+	for _, cc := range contains {
+		if cc.Code != "" {
+			c[cc.System] = append(c[cc.System], cc.Code)
+		}
+		c.addContains(cc.Contains)
+	}
+}
+
+// contains returns true if the code is part of the ValueSet, an empty system matches any system
+func (c codes) contains(system string, code string) bool {
+	for s, list := range c {
+		if system != "" && s != system {
+			continue
+		}
+		if list == nil || contains(list, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// Profile is a StructureDefinition prepared for validation
+type Profile struct {
+	URL string
+	// FHIRVersion of the profile, empty when the StructureDefinition does not specify one
+	FHIRVersion string
+	Type        string
+	elements    []ElementDefinition
+}
+
+// ErrNotAStructureDefinition is returned when loading a resource that is not a StructureDefinition
+var ErrNotAStructureDefinition = errors.New("resource is not a StructureDefinition")
+
+// ParseStructureDefinition reads a StructureDefinition and prepares it for validation.
+// The snapshot is used when present, otherwise the differential. Slices are not supported and skipped.
+func ParseStructureDefinition(data []byte) (*Profile, error) {
+	var sd StructureDefinition
+	if err := json.Unmarshal(data, &sd); err != nil {
+		return nil, err
+	}
+	if sd.ResourceType != "StructureDefinition" {
+		return nil, ErrNotAStructureDefinition
+	}
+	if sd.URL == "" || sd.Type == "" {
+		return nil, fmt.Errorf("StructureDefinition %s: url and type are required", sd.Name)
+	}
+
+	profile := &Profile{URL: sd.URL, Type: sd.Type}
+	if sd.FhirVersion != "" {
+		version, err := ParseFHIRVersion(sd.FhirVersion)
+		if err != nil {
+			return nil, fmt.Errorf("StructureDefinition %s: %w", sd.URL, err)
+		}
+		profile.FHIRVersion = version
+	}
+
+	elements := sd.Differential
+	if sd.Snapshot != nil {
+		elements = sd.Snapshot
+	}
+	if elements == nil {
+		return nil, fmt.Errorf("StructureDefinition %s: snapshot or differential is required", sd.URL)
+	}
+	for _, e := range elements.Element {
+		if e.SliceName != "" || strings.Contains(e.ID, ":") {
+			continue
+		}
+		if !strings.HasPrefix(e.Path, sd.Type+".") {
+			// the root element or an element of another type
+			continue
+		}
+		if e.Max != "" && e.Max != "*" {
+			if _, err := strconv.Atoi(e.Max); err != nil {
+				return nil, fmt.Errorf("StructureDefinition %s: invalid max %s for %s", sd.URL, e.Max, e.Path)
+			}
+		}
+		profile.elements = append(profile.elements, e)
+	}
+
+	return profile, nil
+}
+
+// node is a value in the document with its "provision.actor.0" style field
+type node struct {
+	field string
+	value interface{}
+}
+
+// validate checks the resource against the elements of the profile, bindings are checked with the given ValueSets
+func (p *Profile) validate(resource map[string]interface{}, valueSets map[string]codes) []ValidationError {
+	var errs []ValidationError
+
+	for _, e := range p.elements {
+		segments := strings.Split(strings.TrimPrefix(e.Path, p.Type+"."), ".")
+		parents := []node{{field: "(root)", value: resource}}
+		for _, s := range segments[:len(segments)-1] {
+			parents = children(parents, s)
+		}
+
+		name := segments[len(segments)-1]
+		for _, parent := range parents {
+			items := children([]node{parent}, name)
+			errs = append(errs, p.cardinality(e, parent.field, strings.TrimSuffix(name, "[x]"), len(items))...)
+
+			for _, item := range items {
+				errs = append(errs, p.value(e, item, valueSets)...)
+			}
+		}
+	}
+
+	return errs
+}
+
+func (p *Profile) cardinality(e ElementDefinition, field string, property string, count int) []ValidationError {
+	min := 0
+	if e.Min != nil {
+		min = *e.Min
+	}
+	max, err := strconv.Atoi(e.Max)
+	if err != nil {
+		max = -1
+	}
+
+	switch {
+	case count < min && min == 1:
+		return []ValidationError{p.error(field, property, "required", "", "", "%s is required by profile %s", property, p.URL)}
+	case count < min:
+		return []ValidationError{p.error(field, property, "min", strconv.Itoa(min), strconv.Itoa(count),
+			"%s must occur at least %d times in profile %s", property, min, p.URL)}
+	case max >= 0 && count > max && max == 0:
+		return []ValidationError{p.error(field, property, "forbidden", "", "", "%s is not allowed by profile %s", property, p.URL)}
+	case max >= 0 && count > max:
+		return []ValidationError{p.error(field, property, "max", e.Max, strconv.Itoa(count),
+			"%s may occur at most %d times in profile %s", property, max, p.URL)}
+	}
+	return nil
+}
+
+func (p *Profile) value(e ElementDefinition, item node, valueSets map[string]codes) []ValidationError {
+	var errs []ValidationError
+
+	if e.Fixed != nil && !reflect.DeepEqual(e.Fixed, item.value) {
+		errs = append(errs, p.error(item.field, "", "fixed", jsonString(e.Fixed), jsonString(item.value),
+			"value must be %s in profile %s", jsonString(e.Fixed), p.URL))
+	}
+	if e.Pattern != nil && !matchesPattern(item.value, e.Pattern) {
+		errs = append(errs, p.error(item.field, "", "pattern", jsonString(e.Pattern), jsonString(item.value),
+			"value must match %s in profile %s", jsonString(e.Pattern), p.URL))
+	}
+	if e.Binding != nil && e.Binding.Strength == bindingRequired {
+		// bindings to ValueSets that are not loaded can not be checked
+		url := strings.SplitN(e.Binding.ValueSet, "|", 2)[0]
+		if vs, ok := valueSets[url]; ok && !inValueSet(item.value, vs) {
+			errs = append(errs, p.error(item.field, "", "binding", url, jsonString(item.value),
+				"value must be a code from %s in profile %s", url, p.URL))
+		}
+	}
+
+	return errs
+}
+
+// error creates the violation for the property of a field or, without property, for the field itself
+func (p *Profile) error(field string, property string, rule string, expected string, actual string, format string, a ...interface{}) ValidationError {
+	return ValidationError{
+		Type:     TypeConstraint,
+		Code:     codeFromField("profile", field, property, rule),
+		Pointer:  pointerFromField(field, property),
+		Message:  fmt.Sprintf("%s: %s", field, fmt.Sprintf(format, a...)),
+		Expected: expected,
+		Actual:   actual,
+		Severity: SeverityError,
+	}
+}
+
+// children returns the values of the given element of all nodes, arrays are flattened. An element ending with [x] matches all types of the choice.
+func children(nodes []node, element string) []node {
+	var result []node
+	for _, n := range nodes {
+		m, ok := n.value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		var keys []string
+		if choice := strings.TrimSuffix(element, "[x]"); choice != element {
+			for k := range m {
+				if isChoice(k, choice) {
+					keys = append(keys, k)
+				}
+			}
+		} else if _, ok := m[element]; ok {
+			keys = append(keys, element)
+		}
+
+		for _, k := range keys {
+			field := k
+			if n.field != "(root)" {
+				field = n.field + "." + k
+			}
+			if list, ok := m[k].([]interface{}); ok {
+				for i, v := range list {
+					result = append(result, node{field: fmt.Sprintf("%s.%d", field, i), value: v})
+				}
+				continue
+			}
+			result = append(result, node{field: field, value: m[k]})
+		}
+	}
+	return result
+}
+
+// isChoice returns true if the key is the name of the choice element followed by a type, eg: valueString for value
+func isChoice(key string, name string) bool {
+	return len(key) > len(name) && strings.HasPrefix(key, name) && key[len(name)] >= 'A' && key[len(name)] <= 'Z'
+}
+
+// matchesPattern returns true when the value contains the pattern: all properties of a pattern object must match and every item of a pattern array must match an item of the value
+func matchesPattern(value interface{}, pattern interface{}) bool {
+	switch p := pattern.(type) {
+	case map[string]interface{}:
+		v, ok := value.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, pv := range p {
+			if !matchesPattern(v[k], pv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		v, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, pi := range p {
+			found := false
+			for _, vi := range v {
+				if matchesPattern(vi, pi) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(value, pattern)
+}
+
+// inValueSet checks a code, Coding or CodeableConcept against the ValueSet, a CodeableConcept needs one matching coding
+func inValueSet(value interface{}, vs codes) bool {
+	switch v := value.(type) {
+	case string:
+		return vs.contains("", v)
+	case map[string]interface{}:
+		if codings, ok := v["coding"]; ok {
+			for _, coding := range objects(codings) {
+				if inValueSet(coding, vs) {
+					return true
+				}
+			}
+			return false
+		}
+		system, _ := v["system"].(string)
+		code, _ := v["code"].(string)
+		return code != "" && vs.contains(system, code)
+	}
+	return false
+}
+
+func jsonString(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -0,0 +1,243 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testProfile = `{
+  "resourceType": "StructureDefinition",
+  "url": "http://example.org/StructureDefinition/test",
+  "fhirVersion": "4.0.1",
+  "type": "Consent",
+  "differential": {
+    "element": [
+      {"path": "Consent"},
+      {"path": "Consent.patient", "min": 1, "max": "1"},
+      {"path": "Consent.patient.display", "max": "0"},
+      {"path": "Consent.patient.identifier.system", "fixedUri": "urn:oid:2.16.840.1.113883.2.4.6.3"},
+      {"path": "Consent.organization", "min": 2, "max": "*"},
+      {"path": "Consent.performer", "max": "1"},
+      {"path": "Consent.source[x]", "min": 1, "max": "1"},
+      {"path": "Consent.policyRule", "binding": {"strength": "required", "valueSet": "http://example.org/ValueSet/policy|1.0"}},
+      {"path": "Consent.provision.actor.role", "patternCodeableConcept": {"coding": [{"system": "http://terminology.hl7.org/CodeSystem/v3-ParticipationType", "code": "PRCP"}]}},
+      {"path": "Consent.provision.actor", "sliceName": "ignored", "min": 5}
+    ]
+  }
+}`
+
+func TestParseStructureDefinition(t *testing.T) {
+	t.Run("differential", func(t *testing.T) {
+		p, err := ParseStructureDefinition([]byte(testProfile))
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "http://example.org/StructureDefinition/test", p.URL)
+			assert.Equal(t, FHIRVersionR4, p.FHIRVersion)
+			assert.Equal(t, "Consent", p.Type)
+			// the root element and slices are skipped
+			assert.Len(t, p.elements, 8)
+			assert.Equal(t, "urn:oid:2.16.840.1.113883.2.4.6.3", p.elements[2].Fixed)
+		}
+	})
+
+	t.Run("snapshot before differential", func(t *testing.T) {
+		p, err := ParseStructureDefinition([]byte(`{"resourceType": "StructureDefinition", "url": "u", "type": "Consent",
+			"snapshot": {"element": [{"path": "Consent.patient", "min": 1}]},
+			"differential": {"element": []}}`))
+
+		if assert.NoError(t, err) {
+			assert.Len(t, p.elements, 1)
+		}
+	})
+
+	t.Run("other resource type", func(t *testing.T) {
+		_, err := ParseStructureDefinition([]byte(`{"resourceType": "ValueSet"}`))
+
+		assert.Equal(t, ErrNotAStructureDefinition, err)
+	})
+
+	t.Run("missing url", func(t *testing.T) {
+		_, err := ParseStructureDefinition([]byte(`{"resourceType": "StructureDefinition", "type": "Consent"}`))
+
+		assert.Error(t, err)
+	})
+
+	t.Run("missing elements", func(t *testing.T) {
+		_, err := ParseStructureDefinition([]byte(`{"resourceType": "StructureDefinition", "url": "u", "type": "Consent"}`))
+
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid max", func(t *testing.T) {
+		_, err := ParseStructureDefinition([]byte(`{"resourceType": "StructureDefinition", "url": "u", "type": "Consent",
+			"differential": {"element": [{"path": "Consent.patient", "max": "many"}]}}`))
+
+		assert.Error(t, err)
+	})
+
+	t.Run("unsupported FHIR version", func(t *testing.T) {
+		_, err := ParseStructureDefinition([]byte(`{"resourceType": "StructureDefinition", "url": "u", "type": "Consent", "fhirVersion": "1.0.2",
+			"differential": {"element": []}}`))
+
+		assert.Error(t, err)
+	})
+}
+
+func TestProfile_validate(t *testing.T) {
+	profile, err := ParseStructureDefinition([]byte(testProfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valueSets := map[string]codes{
+		"http://example.org/ValueSet/policy": {"http://terminology.hl7.org/CodeSystem/v3-ActCode": {"OPTIN", "OPTOUT"}},
+	}
+
+	validate := func(document string) []ValidationError {
+		var resource map[string]interface{}
+		if err := json.Unmarshal([]byte(document), &resource); err != nil {
+			t.Fatal(err)
+		}
+		return profile.validate(resource, valueSets)
+	}
+
+	valid := `{
+		"resourceType": "Consent",
+		"patient": {"identifier": {"system": "urn:oid:2.16.840.1.113883.2.4.6.3", "value": "999999990"}},
+		"organization": [{"display": "a"}, {"display": "b"}],
+		"sourceAttachment": {"contentType": "application/pdf"},
+		"policyRule": {"coding": [{"system": "http://terminology.hl7.org/CodeSystem/v3-ActCode", "code": "OPTIN"}]},
+		"provision": {"actor": [{"role": {"coding": [{"system": "http://terminology.hl7.org/CodeSystem/v3-ParticipationType", "code": "PRCP", "display": "x"}]}}]}
+	}`
+
+	t.Run("valid", func(t *testing.T) {
+		assert.Empty(t, validate(valid))
+	})
+
+	t.Run("cardinality", func(t *testing.T) {
+		errs := validate(`{
+			"resourceType": "Consent",
+			"organization": [{}],
+			"performer": [{}, {}],
+			"sourceAttachment": {},
+			"sourceReference": {}
+		}`)
+
+		assert.Equal(t, []string{
+			"(root): patient is required by profile http://example.org/StructureDefinition/test",
+			"(root): organization must occur at least 2 times in profile http://example.org/StructureDefinition/test",
+			"(root): performer may occur at most 1 times in profile http://example.org/StructureDefinition/test",
+			"(root): source may occur at most 1 times in profile http://example.org/StructureDefinition/test",
+		}, messages(errs))
+		assert.Equal(t, "profile.patient-required", errs[0].Code)
+		assert.Equal(t, "/patient", errs[0].Pointer)
+		assert.Equal(t, "2", errs[1].Expected)
+		assert.Equal(t, "1", errs[1].Actual)
+	})
+
+	t.Run("forbidden element", func(t *testing.T) {
+		var resource map[string]interface{}
+		_ = json.Unmarshal([]byte(valid), &resource)
+		resource["patient"].(map[string]interface{})["display"] = "P. Patient"
+
+		errs := profile.validate(resource, valueSets)
+
+		if assert.Len(t, errs, 1) {
+			assert.Equal(t, "patient: display is not allowed by profile http://example.org/StructureDefinition/test", errs[0].Message)
+			assert.Equal(t, "/patient/display", errs[0].Pointer)
+		}
+	})
+
+	t.Run("fixed value", func(t *testing.T) {
+		errs := validate(`{
+			"resourceType": "Consent",
+			"patient": {"identifier": {"system": "urn:oid:2.16.840.1.113883.2.4.6.1", "value": "00000000"}},
+			"organization": [{}, {}],
+			"sourceAttachment": {}
+		}`)
+
+		if assert.Len(t, errs, 1) {
+			assert.Equal(t, TypeConstraint, errs[0].Type)
+			assert.Equal(t, "/patient/identifier/system", errs[0].Pointer)
+			assert.Equal(t, `"urn:oid:2.16.840.1.113883.2.4.6.3"`, errs[0].Expected)
+			assert.Equal(t, `"urn:oid:2.16.840.1.113883.2.4.6.1"`, errs[0].Actual)
+		}
+	})
+
+	t.Run("pattern", func(t *testing.T) {
+		errs := validate(`{
+			"resourceType": "Consent",
+			"patient": {},
+			"organization": [{}, {}],
+			"sourceAttachment": {},
+			"provision": {"actor": [
+				{"role": {"coding": [{"system": "http://terminology.hl7.org/CodeSystem/v3-ParticipationType", "code": "PRCP"}]}},
+				{"role": {"coding": [{"system": "http://terminology.hl7.org/CodeSystem/v3-ParticipationType", "code": "AUT"}]}}
+			]}
+		}`)
+
+		if assert.Len(t, errs, 1) {
+			assert.Equal(t, "/provision/actor/1/role", errs[0].Pointer)
+		}
+	})
+
+	t.Run("required binding", func(t *testing.T) {
+		errs := validate(`{
+			"resourceType": "Consent",
+			"patient": {},
+			"organization": [{}, {}],
+			"sourceAttachment": {},
+			"policyRule": {"coding": [{"system": "http://terminology.hl7.org/CodeSystem/v3-ActCode", "code": "OPTOUTE"}]}
+		}`)
+
+		if assert.Len(t, errs, 1) {
+			assert.Equal(t, "policyRule: value must be a code from http://example.org/ValueSet/policy in profile http://example.org/StructureDefinition/test", errs[0].Message)
+		}
+	})
+
+	t.Run("binding to unknown ValueSet is not checked", func(t *testing.T) {
+		var resource map[string]interface{}
+		_ = json.Unmarshal([]byte(valid), &resource)
+		resource["policyRule"] = map[string]interface{}{"text": "anything"}
+
+		assert.Empty(t, profile.validate(resource, nil))
+	})
+}
+
+func TestValueSet_codes(t *testing.T) {
+	var vs ValueSet
+	_ = json.Unmarshal([]byte(`{
+		"resourceType": "ValueSet",
+		"url": "http://example.org/ValueSet/test",
+		"compose": {"include": [{"system": "a", "concept": [{"code": "1"}]}, {"system": "b"}]},
+		"expansion": {"contains": [{"system": "c", "code": "2", "contains": [{"system": "c", "code": "3"}]}]}
+	}`), &vs)
+
+	c := vs.codes()
+
+	assert.True(t, c.contains("a", "1"))
+	assert.False(t, c.contains("a", "2"))
+	assert.True(t, c.contains("b", "anything"))
+	assert.True(t, c.contains("c", "3"))
+	assert.True(t, c.contains("", "2"))
+}
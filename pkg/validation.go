@@ -20,12 +20,15 @@
 package pkg
 
 import (
+	gojson "encoding/json"
+	"errors"
 	"fmt"
-	"strings"
+	"io/ioutil"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/nuts-foundation/nuts-fhir-validation/schema"
+	core "github.com/nuts-foundation/nuts-go-core"
 	"github.com/sirupsen/logrus"
 	"github.com/thedevsaddam/gojsonq/v2"
 	"github.com/xeipuuv/gojsonschema"
@@ -39,13 +42,53 @@ const ConfigSchemaPath = "schemapath"
 // default use Asset
 const ConfigSchemaPathDefault = ""
 
-// Validator holds the config and schemaLoader for the validator
+// --fullschema config flag
+const ConfigFullSchema = "fullschema"
+
+// default use the reduced Consent schema
+const ConfigFullSchemaDefault = false
+
+// --mode config flag, server or client, the global mode is used when empty
+const ConfigMode = "mode"
+
+// --address config flag
+const ConfigAddress = "address"
+
+// default address of the Nuts node used in client mode
+const ConfigAddressDefault = "localhost:1323"
+
+// --clientTimeout config flag
+const ConfigClientTimeout = "clientTimeout"
+
+// default timeout in seconds for calls to the Nuts node in client mode
+const ConfigClientTimeoutDefault = 10
+
+// consentResourceType is the only resource type validated by the reduced schema
+const consentResourceType = "Consent"
+
+// ErrNotConfigured is returned when validating with a Validator that has not loaded a schema, eg: in client mode
+var ErrNotConfigured = errors.New("validator has no schema, it is not configured or runs in client mode")
+
+// Validator holds the config and compiled schema for the validator
 type Validator struct {
 	Config struct {
-		Schemapath string
+		Mode          string
+		Address       string
+		ClientTimeout int
+		MaxSize       int
+		ReadTimeout   int
+		Version       string
+		Schemapath    string
+		Fullschema    bool
+		Profiles      string
+		Classregistry string
+		Policy        PolicyConfig
+		Reload        bool
 	}
-	schemaLoader gojsonschema.JSONLoader
-	configOnce   sync.Once
+	// active holds the *rules in use, it is replaced by Reload
+	active      atomic.Value
+	reloadMutex sync.Mutex
+	configOnce  sync.Once
 }
 
 // Identifier is a synonym for string
@@ -65,139 +108,165 @@ func ValidatorInstance() *Validator {
 
 // DataClassesFrom extracts the consent provision classes from some fhir json, replaces ResourcesFrom
 // It combines the system and code field to a single string using the correct divider (: or #) based on the type of system
-func DataClassesFrom(jsonq *gojsonq.JSONQ) []string {
-	var dataClasses []string
-	listOfClasses := jsonq.Copy().From("provision.provision").Pluck("class").([]interface{})
-
-	// lists of lists
-	for _, classList := range listOfClasses {
-		cls := classList.([]interface{})
-		for _, cl := range cls {
-			clMap := cl.(map[string]interface{})
-			system := clMap["system"].(string)
-			divider := "#"
-
-			if strings.Index(system, "urn:oid") != -1 {
-				divider = ":"
-			}
-
-			dataClasses = append(dataClasses, fmt.Sprintf("%s%s%s", clMap["system"], divider, clMap["code"]))
-		}
+func DataClassesFrom(jsonq *gojsonq.JSONQ) ([]string, error) {
+	consent, err := consentFrom(jsonq)
+	if err != nil {
+		return nil, err
 	}
-	return dataClasses
+	return consent.DataClasses()
 }
 
 // ResourcesFrom extracts the consent resources from some fhir json, deprecated, replaced by DataClassesFrom
-func ResourcesFrom(jsonq *gojsonq.JSONQ) []string {
+func ResourcesFrom(jsonq *gojsonq.JSONQ) ([]string, error) {
 	return DataClassesFrom(jsonq)
 }
 
 // ActorsFrom extracts the consent actors from some fhir json
-func ActorsFrom(jsonq *gojsonq.JSONQ) []Identifier {
-	var actors []Identifier
-	references := jsonq.Copy().From("provision.actor").Pluck("reference").([]interface{})
-
-	for _, id := range references {
-		refMap := id.(map[string]interface{})
-		idMap := refMap["identifier"].(map[string]interface{})
-		actors = append(actors, Identifier(fmt.Sprintf(concatIdFormat, idMap["system"], idMap["value"])))
+func ActorsFrom(jsonq *gojsonq.JSONQ) ([]Identifier, error) {
+	consent, err := consentFrom(jsonq)
+	if err != nil {
+		return nil, err
 	}
-	return actors
+	return consent.Actors()
 }
 
 // PeriodFrom returns a tuple of time pointers (validFrom, validTo) extracted from FHIR where the validTo may be nil
-func PeriodFrom(jsonq *gojsonq.JSONQ) []*time.Time {
-	start, _ := time.Parse(time.RFC3339, jsonq.Copy().Find("provision.period.start").(string))
-
-	endPeriodJson := jsonq.Copy().Find("provision.period.end")
-	if endPeriodJson == nil {
-		return []*time.Time{&start, nil}
+func PeriodFrom(jsonq *gojsonq.JSONQ) ([]*time.Time, error) {
+	consent, err := consentFrom(jsonq)
+	if err != nil {
+		return nil, err
 	}
-
-	end, _ := time.Parse(time.RFC3339, jsonq.Copy().Find("provision.period.end").(string))
-
-	return []*time.Time{&start, &end}
+	return consent.Period()
 }
 
-func VersionFrom(jsonq *gojsonq.JSONQ) string {
-	return jsonq.Copy().Find("meta.versionId").(string)
+// VersionFrom extracts the meta.versionId from some fhir json
+func VersionFrom(jsonq *gojsonq.JSONQ) (string, error) {
+	consent, err := consentFrom(jsonq)
+	if err != nil {
+		return "", err
+	}
+	return consent.Version()
 }
 
 // SubjectFrom extracts the patient from a given Consent json jsonq source
-func SubjectFrom(jsonq *gojsonq.JSONQ) string {
-	patientIdentifier := fmt.Sprintf(concatIdFormat,
-		jsonq.Copy().Find("patient.identifier.system"),
-		jsonq.Copy().Find("patient.identifier.value"))
-
-	return patientIdentifier
+func SubjectFrom(jsonq *gojsonq.JSONQ) (string, error) {
+	consent, err := consentFrom(jsonq)
+	if err != nil {
+		return "", err
+	}
+	return consent.Subject()
 }
 
 // CustodianFrom extracts the organization from a given Consent json jsonq source
-func CustodianFrom(jsonq *gojsonq.JSONQ) string {
-	organizationIdentifier := fmt.Sprintf(concatIdFormat,
-		jsonq.Copy().Find("organization.[0].identifier.system"),
-		jsonq.Copy().Find("organization.[0].identifier.value"))
-
-	return organizationIdentifier
+func CustodianFrom(jsonq *gojsonq.JSONQ) (string, error) {
+	consent, err := consentFrom(jsonq)
+	if err != nil {
+		return "", err
+	}
+	return consent.Custodian()
 }
 
 // Validate the consent record at the given location (on disk)
-func (ve *Validator) ValidateAgainstSchemaConsentAt(source string) (bool, []string, error) {
-	documentLoader := gojsonschema.NewReferenceLoader(fmt.Sprintf("file://%s", source))
+func (ve *Validator) ValidateAgainstSchemaConsentAt(source string) (bool, []ValidationError, error) {
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return false, nil, err
+	}
 
-	return ve.validateAgainstSchema(documentLoader)
+	return ve.ValidateAgainstSchema(data)
 }
 
-// Validate the consent record against the schema
-func (ve *Validator) ValidateAgainstSchema(json []byte) (bool, []string, error) {
-	documentLoader := gojsonschema.NewBytesLoader(json)
+// Validate the consent record against the schema of the configured FHIR version and the additional rules of the Nuts consent profile
+func (ve *Validator) ValidateAgainstSchema(json []byte) (bool, []ValidationError, error) {
+	return ve.ValidateAgainstSchemaVersion(json, ve.Config.Version)
+}
 
-	return ve.validateAgainstSchema(documentLoader)
+// ValidateAgainstSchemaVersion validates the consent record against the Consent schema of the given FHIR version and the StructureDefinitions in its meta.profile.
+// The rules of the Nuts consent profile and the class registry are defined on R4, records of other versions that pass their schema are mapped onto R4 for those rules.
+// An empty FHIR version is the configured version.
+func (ve *Validator) ValidateAgainstSchemaVersion(json []byte, fhirVersion string) (bool, []ValidationError, error) {
+	fhirVersion, err := ve.fhirVersion(fhirVersion)
+	if err != nil {
+		return false, nil, err
+	}
+	return ve.current().validateSchema(json, fhirVersion)
 }
 
-func (ve *Validator) validateAgainstSchema(loader gojsonschema.JSONLoader) (bool, []string, error) {
-	result, err := gojsonschema.Validate(ve.schemaLoader, loader)
+// validateSchema validates the consent record against the Consent schema and profiles of the parsed FHIR version
+// and against the rules of the Nuts consent profile and the class registry.
+func (r *rules) validateSchema(json []byte, fhirVersion string) (bool, []ValidationError, error) {
+	documentLoader := gojsonschema.NewBytesLoader(json)
+
+	errors, err := r.validateAgainstSchema(documentLoader, fhirVersion)
 	if err != nil {
-		logrus.Error(fmt.Sprintf("The document failed to validate : %s", err.Error()))
 		return false, nil, err
 	}
 
-	var errors []string
+	var resource map[string]interface{}
+	if err := gojson.Unmarshal(json, &resource); err == nil {
+		// other versions are mapped onto R4 when they pass their schema, a broken record can not be mapped
+		if fhirVersion == FHIRVersionR4 || len(errors) == 0 {
+			r4 := mapToR4(resource, fhirVersion)
+			errors = append(errors, validateNutsProfileVersion(gojsonq.New().FromInterface(r4), fhirVersion)...)
+			errors = append(errors, r.classes.validate(r4)...)
+		}
+		errors = append(errors, r.profiles.validate(resource, fhirVersion)...)
+	}
 
-	if result.Valid() {
+	if len(errors) == 0 {
 		logrus.Info("The document is valid")
 		return true, nil, nil
-	} else {
-		logrus.Info("The document is invalid. see errors")
-		for _, desc := range result.Errors() {
-			errors = append(errors, desc.String())
-			logrus.Info(fmt.Sprintf("- %s", desc))
-		}
+	}
+
+	logrus.Info("The document is invalid. see errors")
+	for _, desc := range errors {
+		logrus.Info(fmt.Sprintf("- %s", desc))
 	}
 	return false, errors, nil
 }
 
-// Configure loads the given configurations in the engine.
+func (r *rules) validateAgainstSchema(loader gojsonschema.JSONLoader, fhirVersion string) ([]ValidationError, error) {
+	s, ok := r.schemas[fhirVersion]
+	if !ok {
+		return nil, ErrNotConfigured
+	}
+
+	result, err := s.Validate(loader)
+	if err != nil {
+		logrus.Error(fmt.Sprintf("The document failed to validate : %s", err.Error()))
+		return nil, err
+	}
+
+	var errors []ValidationError
+	for _, desc := range result.Errors() {
+		errors = append(errors, schemaError(desc))
+	}
+	return errors, nil
+}
+
+// Configure loads the given configurations in the engine. In client mode the schemas are not loaded, validation is done by the remote node.
+// The schemas, profiles and policy are loaded as version 1 of the rules, see Reload to load them again.
 func (vb *Validator) Configure() error {
 	var err error
 
 	vb.configOnce.Do(func() {
-		if vb.Config.Schemapath != ConfigSchemaPathDefault {
-			vb.schemaLoader = gojsonschema.NewReferenceLoader(fmt.Sprintf("file://%s", vb.Config.Schemapath))
-		} else {
-			// load from bin data
-			var data []byte
-			data, err = schema.Asset("fhir.schema.json")
-			if err != nil {
-				return
-			}
-
-			vb.schemaLoader = gojsonschema.NewBytesLoader(data)
+		vb.Config.Mode = core.NutsConfig().GetEngineMode(vb.Config.Mode)
+		if vb.Config.Mode != core.ServerEngineMode {
+			return
+		}
+
+		if vb.Config.Version == "" {
+			vb.Config.Version = ConfigFHIRVersionDefault
+		}
+		if vb.Config.Version, err = ParseFHIRVersion(vb.Config.Version); err != nil {
+			return
 		}
 
-		if _, err = vb.schemaLoader.LoadJSON(); err != nil {
+		var r *rules
+		if r, err = vb.loadRules(1); err != nil {
 			return
 		}
+		vb.active.Store(r)
 	})
 
 	return err
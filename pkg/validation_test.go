@@ -25,9 +25,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/nuts-foundation/nuts-fhir-validation/schema"
 	core "github.com/nuts-foundation/nuts-go-core"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/thedevsaddam/gojsonq/v2"
+	"github.com/xeipuuv/gojsonschema"
 )
 
 func TestDefaultValidationBackend_ValidateAgainstSchema(t *testing.T) {
@@ -35,7 +38,7 @@ func TestDefaultValidationBackend_ValidateAgainstSchema(t *testing.T) {
 
 	t.Run("Valid json returns true", func(t *testing.T) {
 
-		bytes, _ := ioutil.ReadFile("../examples/minimal_consent.json")
+		bytes, _ := ioutil.ReadFile("../examples/observation_consent.json")
 
 		outcome, _, _ := client.ValidateAgainstSchema(bytes)
 
@@ -43,6 +46,16 @@ func TestDefaultValidationBackend_ValidateAgainstSchema(t *testing.T) {
 			t.Errorf("Expected outcome to be valid")
 		}
 	})
+
+	t.Run("Schema valid json without Nuts profile fields returns false", func(t *testing.T) {
+
+		bytes, _ := ioutil.ReadFile("../examples/minimal_consent.json")
+
+		outcome, errors, _ := client.ValidateAgainstSchema(bytes)
+
+		assert.False(t, outcome)
+		assert.Contains(t, messages(errors), "(root): patient is required")
+	})
 }
 
 func TestDefaultValidationBackend_ValidateAgainstSchemaConsentAt(t *testing.T) {
@@ -50,7 +63,7 @@ func TestDefaultValidationBackend_ValidateAgainstSchemaConsentAt(t *testing.T) {
 
 	t.Run("Valid json returns true", func(t *testing.T) {
 
-		outcome, _, _ := client.ValidateAgainstSchemaConsentAt("../examples/minimal_consent.json")
+		outcome, _, _ := client.ValidateAgainstSchemaConsentAt("../examples/observation_consent.json")
 
 		if !outcome {
 			t.Errorf("Expected outcome to be valid")
@@ -79,14 +92,27 @@ func TestDefaultValidationBackend_ValidateAgainstSchemaConsentAt(t *testing.T) {
 			t.Errorf("Expected outcome to be invalid")
 		}
 
-		if len(errors) != 2 {
-			t.Errorf("Expected 2 validation errors, got [%d]", len(errors))
+		if len(errors) != 11 {
+			t.Errorf("Expected 11 validation errors, got [%d]", len(errors))
 		}
 	})
+
+	t.Run("Schema errors have a pointer and code", func(t *testing.T) {
+
+		_, errors, _ := client.ValidateAgainstSchema([]byte(`{}`))
+
+		assert.Contains(t, errors, ValidationError{
+			Type:     TypeConstraint,
+			Code:     "required",
+			Pointer:  "/resourceType",
+			Message:  "(root): resourceType is required",
+			Severity: SeverityError,
+		})
+	})
 }
 
-func validationBackend() Validator {
-	client := Validator{}
+func validationBackend() *Validator {
+	client := &Validator{}
 	client.Configure()
 	return client
 }
@@ -94,7 +120,10 @@ func validationBackend() Validator {
 func TestResourcesFrom(t *testing.T) {
 	bytes, _ := ioutil.ReadFile("../examples/observation_consent.json")
 	jsonq := gojsonq.New().JSONString(string(bytes))
-	dataClasses := ResourcesFrom(jsonq)
+	dataClasses, err := ResourcesFrom(jsonq)
+	if !assert.NoError(t, err) {
+		return
+	}
 
 	t.Run("with namespace", func(t *testing.T) {
 		assert.Equal(t, "http://hl7.org/fhir/resource-types#Observation", dataClasses[0])
@@ -114,7 +143,10 @@ func TestPeriodFrom(t *testing.T) {
 	t.Run("with end", func(t *testing.T) {
 		bytes, _ := ioutil.ReadFile("../examples/observation_consent.json")
 		jsonq := gojsonq.New().JSONString(string(bytes))
-		got := PeriodFrom(jsonq)
+		got, err := PeriodFrom(jsonq)
+		if !assert.NoError(t, err) {
+			return
+		}
 		assert.Equal(t, start, *got[0])
 		assert.Equal(t, end, *got[1])
 	})
@@ -122,16 +154,133 @@ func TestPeriodFrom(t *testing.T) {
 	t.Run("without end", func(t *testing.T) {
 		bytes, _ := ioutil.ReadFile("../examples/observation_consent_unl.json")
 		jsonq := gojsonq.New().JSONString(string(bytes))
-		got := PeriodFrom(jsonq)
+		got, err := PeriodFrom(jsonq)
+		if !assert.NoError(t, err) {
+			return
+		}
 		assert.Equal(t, start, *got[0])
 		assert.Nil(t, got[1])
 	})
 
+	t.Run("without period", func(t *testing.T) {
+		jsonq := gojsonq.New().JSONString(`{"resourceType": "Consent", "provision": {}}`)
+		_, err := PeriodFrom(jsonq)
+		assert.EqualError(t, err, "provision.period: value is missing")
+	})
+
+	t.Run("partial dates", func(t *testing.T) {
+		jsonq := gojsonq.New().JSONString(`{"resourceType": "Consent", "provision": {"period": {"start": "2016-06-23", "end": "2017-02"}}}`)
+		period, err := PeriodFrom(jsonq)
+		if assert.NoError(t, err) {
+			assert.Equal(t, time.Date(2016, 6, 23, 0, 0, 0, 0, time.UTC), *period[0])
+			assert.Equal(t, time.Date(2017, 2, 28, 23, 59, 59, 999999999, time.UTC), *period[1])
+		}
+	})
+
+	t.Run("invalid start", func(t *testing.T) {
+		jsonq := gojsonq.New().JSONString(`{"resourceType": "Consent", "provision": {"period": {"start": "2016-06-23T12:00"}}}`)
+		_, err := PeriodFrom(jsonq)
+		assert.EqualError(t, err, "provision.period.start: value [2016-06-23T12:00] can not be extracted")
+	})
+
 }
 
 func TestVersionFrom(t *testing.T) {
 	bytes, _ := ioutil.ReadFile("../examples/observation_consent.json")
 	jsonq := gojsonq.New().JSONString(string(bytes))
 
-	assert.Equal(t, "1", VersionFrom(jsonq))
+	t.Run("with versionId", func(t *testing.T) {
+		version, err := VersionFrom(jsonq)
+		assert.NoError(t, err)
+		assert.Equal(t, "1", version)
+	})
+
+	t.Run("without versionId", func(t *testing.T) {
+		_, err := VersionFrom(gojsonq.New().JSONString(`{"resourceType": "Consent", "meta": {}}`))
+		assert.EqualError(t, err, "meta.versionId: value is missing")
+	})
+
+	t.Run("not a consent", func(t *testing.T) {
+		_, err := VersionFrom(gojsonq.New().JSONString(`{"resourceType": "Patient"}`))
+		assert.Error(t, err)
+	})
+}
+
+func TestActorsFrom(t *testing.T) {
+	t.Run("actor with literal reference", func(t *testing.T) {
+		jsonq := gojsonq.New().JSONString(`{"resourceType": "Consent", "provision": {"actor": [{"reference": {"reference": "Organization/1"}}]}}`)
+
+		_, err := ActorsFrom(jsonq)
+
+		assert.EqualError(t, err, "provision.actor.0.reference.identifier: value [Organization/1] can not be extracted")
+	})
+}
+
+func TestValidator_Configure(t *testing.T) {
+	t.Run("full schema validates all resource types", func(t *testing.T) {
+		client := &Validator{}
+		client.Config.Fullschema = true
+		if !assert.NoError(t, client.Configure()) {
+			return
+		}
+
+		_, errors, _ := client.ValidateAgainstSchema([]byte(`{}`))
+
+		assert.Equal(t, []string{"(root): Must validate one and only one schema (oneOf)", "(root): resourceType is required"}, messages(errors))
+	})
+
+	t.Run("schema from path", func(t *testing.T) {
+		client := &Validator{}
+		client.Config.Schemapath = "../schema/fhir.schema.json"
+
+		assert.NoError(t, client.Configure())
+	})
+
+	t.Run("missing schema path returns error", func(t *testing.T) {
+		client := &Validator{}
+		client.Config.Schemapath = "../schema/does_not_exist.json"
+
+		assert.Error(t, client.Configure())
+	})
+}
+
+func BenchmarkValidator_Configure(b *testing.B) {
+	for _, full := range []bool{false, true} {
+		b.Run(fmt.Sprintf("fullschema=%t", full), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				client := &Validator{}
+				client.Config.Fullschema = full
+				if err := client.Configure(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkValidator_ValidateAgainstSchema(b *testing.B) {
+	logrus.SetLevel(logrus.WarnLevel)
+	client := validationBackend()
+	bytes, _ := ioutil.ReadFile("../examples/observation_consent.json")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if valid, _, _ := client.ValidateAgainstSchema(bytes); !valid {
+			b.Fatal("expected valid consent")
+		}
+	}
+}
+
+// BenchmarkValidate_UncompiledSchema shows the cost of compiling the schema for every validation, as was done before
+func BenchmarkValidate_UncompiledSchema(b *testing.B) {
+	data, _ := schema.Asset("fhir.schema.json")
+	schemaLoader := gojsonschema.NewBytesLoader(data)
+	bytes, _ := ioutil.ReadFile("../examples/observation_consent.json")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if result, _ := gojsonschema.Validate(schemaLoader, gojsonschema.NewBytesLoader(bytes)); !result.Valid() {
+			b.Fatal("expected valid consent")
+		}
+	}
 }
@@ -0,0 +1,226 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidateVersion checks if current is a valid update of previous.
+// The versionId must be incremented by 1, lastUpdated may not decrease and subject and custodian must be the same.
+// A later version may only constrain the previous one: it can end the period earlier or remove actors, classes and actions, it can not add or extend them.
+// Classes and actions are compared per actor on the evaluated provision tree, so granting a class to another actor or removing a deny provision is an extension as well.
+// The returned changes are the changes from Diff that do not violate these rules.
+// An error is returned when a value needed for the comparison can not be extracted from one of the records.
+func ValidateVersion(previous *Consent, current *Consent) ([]ValidationError, []Change, error) {
+	var errs []ValidationError
+	var changes []Change
+
+	pv, cv, err := both(previous.Version, current.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+	expected, ok := nextVersion(pv)
+	if !ok {
+		return nil, nil, invalidValue("meta.versionId", pv)
+	}
+	if cv != expected {
+		errs = append(errs, versionError("meta.versionId", "versionId-invalid", expected, cv,
+			"versionId must be %s, the previous version is %s", expected, pv))
+	}
+
+	pu, err := previous.LastUpdated()
+	if err != nil {
+		return nil, nil, err
+	}
+	cu, err := current.LastUpdated()
+	if err != nil {
+		return nil, nil, err
+	}
+	if cu.Before(pu) {
+		errs = append(errs, versionError("meta.lastUpdated", "lastUpdated-invalid", pu.Format(time.RFC3339), cu.Format(time.RFC3339),
+			"lastUpdated may not be before the lastUpdated of the previous version"))
+	}
+
+	ps, cs, err := both(previous.Subject, current.Subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ps != cs {
+		errs = append(errs, versionError("patient.identifier", "subject-changed", ps, cs, "subject can not be changed"))
+	}
+
+	pc, cc, err := both(previous.Custodian, current.Custodian)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pc != cc {
+		errs = append(errs, versionError("organization.0.identifier", "custodian-changed", pc, cc, "custodian can not be changed"))
+	}
+
+	periodErrs, err := comparePeriods(previous, current)
+	if err != nil {
+		return nil, nil, err
+	}
+	errs = append(errs, periodErrs...)
+
+	diff, err := Diff(previous, current)
+	if err != nil {
+		return nil, nil, err
+	}
+	var added []Identifier
+	for _, c := range diff {
+		switch {
+		case c.Type == ChangeActorAdded:
+			added = append(added, Identifier(c.New))
+			errs = append(errs, versionError("provision.actor", "actor-added", "", c.New, "actor %s can not be added in a later version", c.New))
+		case c.Type == ChangeClassAdded:
+			// reported per actor by compareGrants
+		case c.Type == ChangePeriodStart && hasPointer(periodErrs, "/provision/period/start"):
+		case c.Type == ChangePeriodEnd && hasPointer(periodErrs, "/provision/period/end"):
+		default:
+			changes = append(changes, c)
+		}
+	}
+
+	grantErrs, err := compareGrants(previous, current, added)
+	if err != nil {
+		return nil, nil, err
+	}
+	errs = append(errs, grantErrs...)
+
+	return errs, changes, nil
+}
+
+// compareGrants checks that no actor is permitted a class or an action for a class it was not permitted in the previous version.
+// Actors that are reported as added are skipped.
+func compareGrants(previous *Consent, current *Consent, added []Identifier) ([]ValidationError, error) {
+	_, pg, err := previous.permittedActions()
+	if err != nil {
+		return nil, err
+	}
+	actors, cg, err := current.permittedActions()
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []ValidationError
+	for _, actor := range actors {
+		if containsIdentifier(added, actor) {
+			continue
+		}
+		for _, class := range sortedKeys(cg[actor]) {
+			actions, ok := pg[actor][class]
+			if !ok {
+				errs = append(errs, versionError("provision.provision", "class-added", "", class,
+					"class %s can not be added for actor %s in a later version", class, actor))
+				continue
+			}
+			for _, action := range cg[actor][class] {
+				if !contains(actions, action) {
+					errs = append(errs, versionError("provision.provision", "action-added", strings.Join(actions, "|"), action,
+						"action %s can not be added for class %s and actor %s in a later version", action, class, actor))
+				}
+			}
+		}
+	}
+	return errs, nil
+}
+
+// comparePeriods checks that the period of the current version does not start earlier or end later than the previous version
+func comparePeriods(previous *Consent, current *Consent) ([]ValidationError, error) {
+	pp, err := previous.Period()
+	if err != nil {
+		return nil, err
+	}
+	cp, err := current.Period()
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []ValidationError
+	if cp[0].Before(*pp[0]) {
+		errs = append(errs, versionError("provision.period.start", "period-extended", pp[0].Format(time.RFC3339), cp[0].Format(time.RFC3339),
+			"period can not start before the start of the previous version"))
+	}
+
+	if pp[1] != nil && (cp[1] == nil || cp[1].After(*pp[1])) {
+		errs = append(errs, versionError("provision.period.end", "period-extended", pp[1].Format(time.RFC3339), formatTime(cp[1]),
+			"period can not end after the end of the previous version"))
+	}
+
+	return errs, nil
+}
+
+func hasPointer(errs []ValidationError, pointer string) bool {
+	for _, e := range errs {
+		if e.Pointer == pointer {
+			return true
+		}
+	}
+	return false
+}
+
+// both calls the extractor on the previous and current version
+func both(previous func() (string, error), current func() (string, error)) (string, string, error) {
+	p, err := previous()
+	if err != nil {
+		return "", "", err
+	}
+	c, err := current()
+	if err != nil {
+		return "", "", err
+	}
+	return p, c, nil
+}
+
+// nextVersion returns the versionId that follows the given one
+func nextVersion(version string) (string, bool) {
+	v, err := strconv.Atoi(version)
+	if err != nil || v < 1 {
+		return "", false
+	}
+	return strconv.Itoa(v + 1), true
+}
+
+func versionError(field string, code string, expected string, actual string, format string, a ...interface{}) ValidationError {
+	return ValidationError{
+		Type:     TypeVersion,
+		Code:     "version." + code,
+		Pointer:  pointerFromField(field, ""),
+		Message:  fmt.Sprintf("%s: %s", field, fmt.Sprintf(format, a...)),
+		Expected: expected,
+		Actual:   actual,
+		Severity: SeverityError,
+	}
+}
+
+func sortedKeys(m map[string][]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
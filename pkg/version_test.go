@@ -0,0 +1,186 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package pkg
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateVersion(t *testing.T) {
+	bytes, _ := ioutil.ReadFile("../examples/observation_consent_unl.json")
+	v1 := string(bytes)
+	previous, _ := ParseConsent(bytes)
+
+	next := func(replacements ...string) *Consent {
+		json := strings.Replace(v1, `"versionId": "1"`, `"versionId": "2"`, 1)
+		for i := 0; i < len(replacements); i += 2 {
+			json = strings.Replace(json, replacements[i], replacements[i+1], 1)
+		}
+		consent, err := ParseConsent([]byte(json))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return consent
+	}
+
+	t.Run("ending the period is a valid update", func(t *testing.T) {
+		current := next(`"start": "2016-06-23T17:02:33+10:00"`, `"start": "2016-06-23T17:02:33+10:00", "end": "2017-01-01T00:00:00Z"`)
+
+		errs, changes, err := ValidateVersion(previous, current)
+
+		assert.NoError(t, err)
+		assert.Empty(t, errs)
+		assert.Equal(t, []Change{{Type: ChangePeriodEnd, New: "2017-01-01T00:00:00Z"}}, changes)
+	})
+
+	t.Run("removing a class is a valid update", func(t *testing.T) {
+		current := next(`"system": "urn:oid:1.3.6.1.4.1.54851.1",
+            "code": "MEDICAL"`, `"system": "http://hl7.org/fhir/resource-types",
+            "code": "Observation"`)
+
+		errs, changes, _ := ValidateVersion(previous, current)
+
+		assert.Empty(t, errs)
+		assert.Equal(t, []Change{{Type: ChangeClassRemoved, Old: "urn:oid:1.3.6.1.4.1.54851.1:MEDICAL"}}, changes)
+	})
+
+	t.Run("versionId must be incremented by 1", func(t *testing.T) {
+		errs, _, _ := ValidateVersion(previous, previous)
+
+		assert.Equal(t, []string{"meta.versionId: versionId must be 2, the previous version is 1"}, messages(errs))
+		assert.Equal(t, "version.versionId-invalid", errs[0].Code)
+	})
+
+	t.Run("subject and custodian can not change", func(t *testing.T) {
+		current := next(`"value": "999999990"`, `"value": "999999991"`, `"value": "00000000"
+    },
+    "display"`, `"value": "00000001"
+    },
+    "display"`)
+
+		errs, _, _ := ValidateVersion(previous, current)
+
+		assert.Equal(t, []string{"patient.identifier: subject can not be changed", "organization.0.identifier: custodian can not be changed"}, messages(errs))
+	})
+
+	t.Run("lastUpdated can not decrease", func(t *testing.T) {
+		current := next(`"lastUpdated": "2015`, `"lastUpdated": "2014`)
+
+		errs, _, _ := ValidateVersion(previous, current)
+
+		assert.Len(t, errs, 1)
+		assert.Equal(t, "/meta/lastUpdated", errs[0].Pointer)
+	})
+
+	t.Run("actors can not be added", func(t *testing.T) {
+		current := next(`"value": "00000007"`, `"value": "00000008"`)
+
+		errs, changes, _ := ValidateVersion(previous, current)
+
+		assert.Equal(t, []string{"provision.actor: actor urn:oid:2.16.840.1.113883.2.4.6.1:00000008 can not be added in a later version"}, messages(errs))
+		assert.Equal(t, []Change{{Type: ChangeActorRemoved, Old: "urn:oid:2.16.840.1.113883.2.4.6.1:00000007"}}, changes)
+	})
+
+	// provisions replaces the nested provisions of the example, classes are MEDICAL or SOCIAL and actors 00000007 or 00000008
+	provisions := func(versionId string, nested ...string) *Consent {
+		json := strings.Replace(v1, `"versionId": "1"`, `"versionId": "`+versionId+`"`, 1)
+		json = json[:strings.Index(json, `"provision": [`)] + `"provision": [` + strings.Join(nested, ",") + "]}}"
+		consent, err := ParseConsent([]byte(json))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return consent
+	}
+	provision := func(kind string, actor string, class string, actions ...string) string {
+		var codings []string
+		for _, a := range actions {
+			codings = append(codings, `{"coding": [{"system": "http://terminology.hl7.org/CodeSystem/consentaction", "code": "`+a+`"}]}`)
+		}
+		return `{"type": "` + kind + `",
+			"actor": [{"reference": {"identifier": {"system": "urn:oid:2.16.840.1.113883.2.4.6.1", "value": "` + actor + `"}}}],
+			"action": [` + strings.Join(codings, ",") + `],
+			"class": [{"system": "urn:oid:1.3.6.1.4.1.54851.1", "code": "` + class + `"}]}`
+	}
+
+	t.Run("class can not be permitted to another actor", func(t *testing.T) {
+		previous := provisions("1", provision("permit", "00000007", "MEDICAL", "access"), provision("permit", "00000008", "SOCIAL", "access"))
+		current := provisions("2", provision("permit", "00000007", "MEDICAL", "access"), provision("permit", "00000008", "SOCIAL", "access"),
+			provision("permit", "00000007", "SOCIAL", "access"))
+
+		errs, changes, _ := ValidateVersion(previous, current)
+
+		assert.Equal(t, []string{"provision.provision: class urn:oid:1.3.6.1.4.1.54851.1:SOCIAL can not be added for actor urn:oid:2.16.840.1.113883.2.4.6.1:00000007 in a later version"}, messages(errs))
+		assert.Equal(t, "version.class-added", errs[0].Code)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("deny provision can not be removed", func(t *testing.T) {
+		previous := provisions("1", provision("permit", "00000007", "MEDICAL", "access"), provision("permit", "00000007", "SOCIAL", "access"),
+			provision("deny", "00000007", "SOCIAL"))
+		current := provisions("2", provision("permit", "00000007", "MEDICAL", "access"), provision("permit", "00000007", "SOCIAL", "access"))
+
+		errs, _, _ := ValidateVersion(previous, current)
+
+		assert.Equal(t, []string{"provision.provision: class urn:oid:1.3.6.1.4.1.54851.1:SOCIAL can not be added for actor urn:oid:2.16.840.1.113883.2.4.6.1:00000007 in a later version"}, messages(errs))
+	})
+
+	t.Run("adding a deny provision is a valid update", func(t *testing.T) {
+		previous := provisions("1", provision("permit", "00000007", "MEDICAL", "access"), provision("permit", "00000007", "SOCIAL", "access"))
+		current := provisions("2", provision("permit", "00000007", "MEDICAL", "access"), provision("permit", "00000007", "SOCIAL", "access"),
+			provision("deny", "00000007", "SOCIAL"))
+
+		errs, _, _ := ValidateVersion(previous, current)
+
+		assert.Empty(t, errs)
+	})
+
+	t.Run("action can not be added", func(t *testing.T) {
+		previous := provisions("1", provision("permit", "00000007", "MEDICAL", "access"))
+		current := provisions("2", provision("permit", "00000007", "MEDICAL", "access", "correct"))
+
+		errs, _, _ := ValidateVersion(previous, current)
+
+		assert.Equal(t, []string{"provision.provision: action correct can not be added for class urn:oid:1.3.6.1.4.1.54851.1:MEDICAL and actor urn:oid:2.16.840.1.113883.2.4.6.1:00000007 in a later version"}, messages(errs))
+		assert.Equal(t, "version.action-added", errs[0].Code)
+		assert.Equal(t, "access", errs[0].Expected)
+		assert.Equal(t, "correct", errs[0].Actual)
+	})
+
+	t.Run("removing an action is a valid update", func(t *testing.T) {
+		previous := provisions("1", provision("permit", "00000007", "MEDICAL", "access", "correct"))
+		current := provisions("2", provision("permit", "00000007", "MEDICAL", "access"))
+
+		errs, _, _ := ValidateVersion(previous, current)
+
+		assert.Empty(t, errs)
+	})
+
+	t.Run("missing lastUpdated returns error", func(t *testing.T) {
+		current := next(`"lastUpdated"`, `"_lastUpdated"`)
+
+		_, _, err := ValidateVersion(previous, current)
+
+		assert.EqualError(t, err, "meta.lastUpdated: value is missing")
+	})
+}
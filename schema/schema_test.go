@@ -0,0 +1,104 @@
+/*
+ * Nuts fhir validation
+ * Copyright (C) 2019 Nuts community
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ *
+ */
+
+package schema
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsset(t *testing.T) {
+	t.Run("fhir schema", func(t *testing.T) {
+		data, err := Asset("fhir.schema.json")
+
+		assert.NoError(t, err)
+		assert.NotEmpty(t, data)
+	})
+
+	t.Run("asset in directory", func(t *testing.T) {
+		_, err := Asset("profiles/nuts-consent.json")
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("unknown asset", func(t *testing.T) {
+		_, err := Asset("unknown.json")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestOpen(t *testing.T) {
+	f, err := Open("profiles/nuts-consent.json")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+
+	assert.NoError(t, err)
+	assert.Equal(t, MustAsset("profiles/nuts-consent.json"), data)
+}
+
+func TestList(t *testing.T) {
+	t.Run("all assets", func(t *testing.T) {
+		names, err := List(".")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"classes/nuts.json",
+			"consent.r5.schema.json",
+			"consent.stu3.schema.json",
+			"fhir.schema.json",
+			"profiles/nuts-consent.json",
+		}, names)
+		assert.Equal(t, names, AssetNames())
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		names, err := List("profiles")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"profiles/nuts-consent.json"}, names)
+	})
+
+	t.Run("unknown directory", func(t *testing.T) {
+		_, err := List("unknown")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestInfo(t *testing.T) {
+	info, err := Info("profiles/nuts-consent.json")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "profiles/nuts-consent.json", info.Name)
+	assert.Equal(t, int64(len(MustAsset("profiles/nuts-consent.json"))), info.Size)
+	assert.Len(t, info.SHA256, 64)
+}
+
+func TestVersion(t *testing.T) {
+	assert.Len(t, Version(), 64)
+	assert.Equal(t, Version(), Version())
+}